@@ -0,0 +1,562 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/anishakd4/rssagg/internal/auth"
+	"github.com/anishakd4/rssagg/internal/database"
+	"github.com/anishakd4/rssagg/internal/discover"
+	"github.com/anishakd4/rssagg/internal/search"
+	"github.com/google/uuid"
+)
+
+const commandServe = "serve"
+
+// exportPageSize is how many posts export reads from storage at a time
+const exportPageSize = 500
+
+// errUsage is returned by a command that was given arguments it can't make
+// sense of, runCommand adds the command's usage line to it
+var errUsage = errors.New("invalid arguments")
+
+// command is a subcommand of the binary. run gets the config shared by every
+// command and whatever followed the command's name on the command line.
+type command struct {
+	usage   string
+	summary string
+	run     func(cfg Config, args []string) error
+}
+
+var commands = map[string]command{
+	commandServe: {
+		usage:   "serve",
+		summary: "run the API, scraper and webhook dispatcher (the default)",
+		run:     runServe,
+	},
+	"migrate": {
+		usage:   "migrate up|down [steps]|status",
+		summary: "apply, roll back or list database migrations",
+		run:     runMigrate,
+	},
+	"user": {
+		usage:   "user create <name>",
+		summary: "create a user and print it along with its api key",
+		run:     runUser,
+	},
+	"feed": {
+		usage:   "feed add <url> --user=<id> [--name=<name>]",
+		summary: "add a feed, or the feed a page links to, for a user who follows it",
+		run:     runFeed,
+	},
+	"scrape": {
+		usage:   "scrape once <feed-id>",
+		summary: "fetch a feed right away and store its new posts",
+		run:     runScrape,
+	},
+	"export": {
+		usage:   "export posts --user=<id> [--feed=<id>] [--format=json|csv]",
+		summary: "write a user's posts to stdout, newest first",
+		run:     runExport,
+	},
+}
+
+func printCommands(w io.Writer) {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, name := range names {
+		fmt.Fprintf(tw, "  %s\t%s\n", commands[name].usage, commands[name].summary)
+	}
+	tw.Flush()
+}
+
+// runCommand runs the command picked by cfg, loadConfig has already checked
+// that it exists
+func runCommand(cfg Config) error {
+	cmd := commands[cfg.Command]
+	err := cmd.run(cfg, cfg.Args)
+	if errors.Is(err, errUsage) {
+		return fmt.Errorf("%w\nusage: rssagg [flags] %s", err, cmd.usage)
+	}
+	return err
+}
+
+// commandContext is cancelled on SIGINT/SIGTERM, so a long export or scrape
+// can be interrupted cleanly
+func commandContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+}
+
+// parseCommandFlags parses a command's own flags, which unlike the shared
+// ones may come before, between or after its positional arguments. It
+// returns the positional arguments.
+func parseCommandFlags(fs *flag.FlagSet, args []string) ([]string, error) {
+	fs.SetOutput(io.Discard)
+	positional := []string{}
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, fmt.Errorf("%w: %v", errUsage, err)
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+// openStore opens storage for the commands other than serve. The memory
+// store would be thrown away as soon as the command exits, so they need a
+// real database.
+func openStore(ctx context.Context, cfg Config) (database.Store, error) {
+	if cfg.DBDriver != database.DriverSQLite {
+		return nil, fmt.Errorf("DB_DRIVER must be %s, %s doesn't keep anything between runs", database.DriverSQLite, cfg.DBDriver)
+	}
+	store, err := database.NewStore(ctx, cfg.DBDriver, cfg.DBURL)
+	if err != nil {
+		return nil, fmt.Errorf("can't open database: %w", err)
+	}
+	return store, nil
+}
+
+// newCommandScraper builds a scraper for one off fetches. Nothing listens on
+// its broker or searches its index, the webhook deliveries it queues are sent
+// and its posts indexed by the next server that starts.
+func newCommandScraper(store database.Store, cfg Config) *scraper {
+	m := newMetrics()
+	return newScraper(store, m, newPostBroker(), newWebhookDispatcher(store, m, cfg), search.NewIndex(), cfg)
+}
+
+func printJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func runServe(cfg Config, args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+	return serve(cfg)
+}
+
+// runMigrate works on the database directly rather than through a Store,
+// which would migrate it up as soon as it was opened
+func runMigrate(cfg Config, args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+	steps := 1
+	switch args[0] {
+	case "up", "status":
+		if len(args) != 1 {
+			return errUsage
+		}
+	case "down":
+		if len(args) > 2 {
+			return errUsage
+		}
+		if len(args) == 2 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("%w: steps must be a positive integer", errUsage)
+			}
+			steps = n
+		}
+	default:
+		return errUsage
+	}
+	if cfg.DBDriver != database.DriverSQLite {
+		return fmt.Errorf("DB_DRIVER must be %s, %s has no migrations", database.DriverSQLite, cfg.DBDriver)
+	}
+
+	ctx, stop := commandContext()
+	defer stop()
+
+	db, err := database.Open(cfg.DBURL)
+	if err != nil {
+		return fmt.Errorf("can't open database: %w", err)
+	}
+	defer db.Close()
+
+	switch args[0] {
+	case "up":
+		err = database.Migrate(ctx, db)
+	case "down":
+		err = database.MigrateDown(ctx, db, steps)
+	}
+	if err != nil {
+		return err
+	}
+
+	statuses, err := database.GetMigrationStatus(ctx, db)
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tNAME\tAPPLIED AT")
+	for _, status := range statuses {
+		appliedAt := "pending"
+		if status.AppliedAt.Valid {
+			appliedAt = status.AppliedAt.Time.UTC().Format(time.RFC3339)
+		}
+		fmt.Fprintf(tw, "%v\t%s\t%s\n", status.Version, status.Name, appliedAt)
+	}
+	return tw.Flush()
+}
+
+func runUser(cfg Config, args []string) error {
+	if len(args) != 2 || args[0] != "create" || args[1] == "" {
+		return errUsage
+	}
+
+	ctx, stop := commandContext()
+	defer stop()
+
+	store, err := openStore(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	apiKey, err := auth.NewAPIKey()
+	if err != nil {
+		return fmt.Errorf("couldn't generate api key: %w", err)
+	}
+	feedToken, err := auth.NewSecret()
+	if err != nil {
+		return fmt.Errorf("couldn't generate feed token: %w", err)
+	}
+
+	now := time.Now().UTC()
+	user, err := store.CreateUser(ctx, database.CreateUserParams{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		Name:      args[1],
+		ApiKey:    apiKey,
+		FeedToken: feedToken,
+	})
+	if err != nil {
+		return fmt.Errorf("couldn't create user: %w", err)
+	}
+
+	return printJSON(os.Stdout, databaseUserToUser(user))
+}
+
+// runFeed finds the feed before adding it, the same way POST /v1/feeds does,
+// so a typo in the url is caught here instead of sitting in the scrape queue
+// failing
+func runFeed(cfg Config, args []string) error {
+	fs := flag.NewFlagSet("feed", flag.ContinueOnError)
+	userIDStr := fs.String("user", "", "id of the user adding the feed")
+	name := fs.String("name", "", "name of the feed, defaults to its title")
+	args, err := parseCommandFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 2 || args[0] != "add" {
+		return errUsage
+	}
+	feedURL := args[1]
+	if !isFeedURL(feedURL) {
+		return errors.New("url must be an absolute http(s) URL")
+	}
+	userID, err := uuid.Parse(*userIDStr)
+	if err != nil {
+		return fmt.Errorf("%w: --user must be a user id", errUsage)
+	}
+
+	ctx, stop := commandContext()
+	defer stop()
+
+	store, err := openStore(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	user, err := store.GetUserByID(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("couldn't find user %s", userID)
+	}
+	if err != nil {
+		return fmt.Errorf("couldn't get user: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, discoverTimeout)
+	defer cancel()
+	found, err := discover.Find(ctx, newOutboundClient(fetchTimeout, cfg.AllowPrivateNetworks), feedURL)
+	if err != nil {
+		return fmt.Errorf("couldn't find a feed at url: %w", err)
+	}
+	if *name == "" {
+		*name = strings.TrimSpace(found.Feed.Title)
+	}
+	if *name == "" {
+		*name = found.URL
+	}
+
+	now := time.Now().UTC()
+	feed, err := store.CreateFeed(ctx, database.CreateFeedParams{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		Name:      *name,
+		Url:       found.URL,
+		UserID:    user.ID,
+	})
+	if database.IsUniqueViolation(err) {
+		return errors.New("a feed with that url already exists")
+	}
+	if err != nil {
+		return fmt.Errorf("couldn't create feed: %w", err)
+	}
+
+	feedFollow, err := store.CreateFeedFollow(ctx, database.CreateFeedFollowParams{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		UserID:    user.ID,
+		FeedID:    feed.ID,
+	})
+	if err != nil {
+		return fmt.Errorf("couldn't create feed follow: %w", err)
+	}
+
+	type response struct {
+		Feed       Feed       `json:"feed"`
+		FeedFollow FeedFollow `json:"feed_follow"`
+	}
+	return printJSON(os.Stdout, response{
+		Feed:       databaseFeedToFeed(feed),
+		FeedFollow: databaseFeedFollowToFeedFollow(feedFollow),
+	})
+}
+
+// runScrape scrapes one feed whether or not it's due, and fails if the fetch
+// does. It doesn't revive a dead feed.
+func runScrape(cfg Config, args []string) error {
+	if len(args) != 2 || args[0] != "once" {
+		return errUsage
+	}
+	feedID, err := uuid.Parse(args[1])
+	if err != nil {
+		return fmt.Errorf("%w: feed-id must be a feed id", errUsage)
+	}
+
+	ctx, stop := commandContext()
+	defer stop()
+
+	store, err := openStore(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	feed, err := store.GetFeedByID(ctx, feedID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("couldn't find feed %s", feedID)
+	}
+	if err != nil {
+		return fmt.Errorf("couldn't get feed: %w", err)
+	}
+
+	inserted, err := newCommandScraper(store, cfg).scrapeFeed(ctx, feed)
+	if err != nil {
+		return fmt.Errorf("couldn't scrape feed %s: %w", feed.Name, err)
+	}
+	fmt.Printf("%v new posts\n", inserted)
+	return nil
+}
+
+// runExport streams every post of the user's feeds, a page at a time, so
+// the export doesn't have to fit in memory
+func runExport(cfg Config, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	userIDStr := fs.String("user", "", "id of the user whose posts to export")
+	feedIDStr := fs.String("feed", "", "only export posts of this feed")
+	format := fs.String("format", "json", "json or csv")
+	args, err := parseCommandFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 || args[0] != "posts" {
+		return errUsage
+	}
+	if *format != "json" && *format != "csv" {
+		return fmt.Errorf("%w: --format must be json or csv", errUsage)
+	}
+	userID, err := uuid.Parse(*userIDStr)
+	if err != nil {
+		return fmt.Errorf("%w: --user must be a user id", errUsage)
+	}
+	feedID := uuid.NullUUID{}
+	if *feedIDStr != "" {
+		id, err := uuid.Parse(*feedIDStr)
+		if err != nil {
+			return fmt.Errorf("%w: --feed must be a feed id", errUsage)
+		}
+		feedID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+
+	ctx, stop := commandContext()
+	defer stop()
+
+	store, err := openStore(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	if _, err := store.GetUserByID(ctx, userID); errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("couldn't find user %s", userID)
+	} else if err != nil {
+		return fmt.Errorf("couldn't get user: %w", err)
+	}
+
+	var out postWriter
+	if *format == "csv" {
+		out = newCSVPostWriter(os.Stdout)
+	} else {
+		out = newJSONPostWriter(os.Stdout)
+	}
+
+	after := database.Cursor{}
+	for {
+		posts, err := store.GetPostsForUser(ctx, database.GetPostsForUserParams{
+			UserID: userID,
+			FeedID: feedID,
+			After:  after,
+			Desc:   true,
+			Limit:  exportPageSize,
+		})
+		if err != nil {
+			return fmt.Errorf("couldn't get posts: %w", err)
+		}
+		withStates, err := postsWithStates(ctx, store, userID, posts)
+		if err != nil {
+			return fmt.Errorf("couldn't get post states: %w", err)
+		}
+		for _, post := range withStates {
+			if err := out.write(post); err != nil {
+				return err
+			}
+		}
+		if len(posts) < exportPageSize {
+			break
+		}
+		last := posts[len(posts)-1]
+		after = database.Cursor{Time: last.PublishedAt, ID: last.ID, Valid: true}
+	}
+	return out.close()
+}
+
+// postWriter writes an export one post at a time
+type postWriter interface {
+	write(post Post) error
+	close() error
+}
+
+// jsonPostWriter writes the posts as a single JSON array
+type jsonPostWriter struct {
+	w     io.Writer
+	count int
+}
+
+func newJSONPostWriter(w io.Writer) *jsonPostWriter {
+	return &jsonPostWriter{w: w}
+}
+
+func (j *jsonPostWriter) write(post Post) error {
+	data, err := json.Marshal(post)
+	if err != nil {
+		return err
+	}
+	sep := ",\n"
+	if j.count == 0 {
+		sep = "[\n"
+	}
+	j.count++
+	_, err = fmt.Fprintf(j.w, "%s  %s", sep, data)
+	return err
+}
+
+func (j *jsonPostWriter) close() error {
+	if j.count == 0 {
+		_, err := fmt.Fprintln(j.w, "[]")
+		return err
+	}
+	_, err := fmt.Fprintln(j.w, "\n]")
+	return err
+}
+
+var csvPostHeader = []string{"id", "feed_id", "title", "url", "description", "summary", "published_at", "guid", "created_at", "read_at", "starred_at"}
+
+type csvPostWriter struct {
+	w           *csv.Writer
+	wroteHeader bool
+}
+
+func newCSVPostWriter(w io.Writer) *csvPostWriter {
+	return &csvPostWriter{w: csv.NewWriter(w)}
+}
+
+func (c *csvPostWriter) write(post Post) error {
+	if !c.wroteHeader {
+		c.wroteHeader = true
+		if err := c.w.Write(csvPostHeader); err != nil {
+			return err
+		}
+	}
+	return c.w.Write([]string{
+		post.ID.String(),
+		post.FeedID.String(),
+		post.Title,
+		post.Url,
+		post.Description,
+		post.Summary,
+		post.PublishedAt.UTC().Format(time.RFC3339),
+		post.Guid,
+		post.CreatedAt.UTC().Format(time.RFC3339),
+		formatTimePtr(post.ReadAt),
+		formatTimePtr(post.StarredAt),
+	})
+}
+
+// formatTimePtr leaves a csv cell empty for a null time
+func formatTimePtr(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func (c *csvPostWriter) close() error {
+	// an empty export still gets a header
+	if !c.wroteHeader {
+		c.wroteHeader = true
+		c.w.Write(csvPostHeader)
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
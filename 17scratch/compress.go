@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+)
+
+// minCompressSize is the smallest body worth compressing, below it the
+// framing overhead eats most of the savings
+const minCompressSize = 1 << 10
+
+// supportedEncodings is in order of preference when the client accepts
+// several equally
+var supportedEncodings = []string{"zstd", "gzip"}
+
+// zstdEncoder is shared, EncodeAll is safe for concurrent use
+var zstdEncoder, _ = zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+
+// negotiateEncoding picks a Content-Encoding from the request's
+// Accept-Encoding, or "" to send the body as is
+func negotiateEncoding(r *http.Request) string {
+	qualities := map[string]float64{}
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		q := 1.0
+		if qStr, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(qStr, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		qualities[name] = q
+	}
+
+	best, bestQ := "", 0.0
+	for _, encoding := range supportedEncodings {
+		q, ok := qualities[encoding]
+		if !ok {
+			q, ok = qualities["*"]
+		}
+		if ok && q > bestQ {
+			best, bestQ = encoding, q
+		}
+	}
+	return best
+}
+
+// writeBody writes data with Content-Length set, compressed if the client
+// accepts it and it's big enough to be worth it
+func writeBody(w http.ResponseWriter, r *http.Request, code int, data []byte) {
+	w.Header().Add("Vary", "Accept-Encoding")
+	if len(data) >= minCompressSize {
+		switch encoding := negotiateEncoding(r); encoding {
+		case "zstd":
+			data = zstdEncoder.EncodeAll(data, nil)
+			w.Header().Set("Content-Encoding", encoding)
+		case "gzip":
+			buf := &bytes.Buffer{}
+			gz := gzip.NewWriter(buf)
+			gz.Write(data)
+			gz.Close()
+			data = buf.Bytes()
+			w.Header().Set("Content-Encoding", encoding)
+		}
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.WriteHeader(code)
+	w.Write(data)
+}
+
+// compressingWriter is writeBody for responses streamed in pieces, whose
+// length isn't known up front. It must be called before WriteHeader, and the
+// returned close func once the body is written.
+func compressingWriter(w http.ResponseWriter, r *http.Request) (io.Writer, func() error) {
+	w.Header().Add("Vary", "Accept-Encoding")
+	switch encoding := negotiateEncoding(r); encoding {
+	case "zstd":
+		zw, err := zstd.NewWriter(w, zstd.WithEncoderConcurrency(1))
+		if err != nil {
+			break
+		}
+		w.Header().Set("Content-Encoding", encoding)
+		return zw, zw.Close
+	case "gzip":
+		gz := gzip.NewWriter(w)
+		w.Header().Set("Content-Encoding", encoding)
+		return gz, gz.Close
+	}
+	return w, func() error { return nil }
+}
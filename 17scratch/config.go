@@ -0,0 +1,281 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anishakd4/rssagg/internal/database"
+	"github.com/joho/godotenv"
+)
+
+type Config struct {
+	Port           string
+	DBDriver       string
+	DBURL          string
+	AllowedOrigins []string
+
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	ShutdownTimeout   time.Duration
+
+	ScrapeInterval    time.Duration
+	ScrapeBatchSize   int
+	ScrapeConcurrency int
+	ScrapeMaxFailures int
+	ScrapeMaxBackoff  time.Duration
+
+	// SummaryLength caps the plain text summary stored with each post
+	SummaryLength int
+
+	WebhookInterval    time.Duration
+	WebhookTimeout     time.Duration
+	WebhookMaxAttempts int
+	WebhookMaxBackoff  time.Duration
+
+	// AllowPrivateNetworks lets feeds and webhooks point at loopback and
+	// private addresses, for development
+	AllowPrivateNetworks bool
+
+	// RateLimits is keyed by route group, see rateLimiters
+	RateLimits map[string]rateLimit
+
+	PrintConfig bool
+
+	// Command is the subcommand to run and Args what follows it, see commands
+	Command string
+	Args    []string
+
+	// resolved holds the raw value of every configVar for printing
+	resolved map[string]string
+}
+
+// configVar ties a Config field to its env var and flag. Values are resolved
+// as strings (default, then env, then flag) and only then parsed by set, so
+// every bad value can be reported together.
+type configVar struct {
+	env    string
+	flag   string
+	def    string
+	usage  string
+	secret bool
+	set    func(cfg *Config, val string) error
+}
+
+var configVars = []configVar{
+	{env: "PORT", flag: "port", usage: "port to listen on",
+		set: stringField(func(c *Config) *string { return &c.Port })},
+	{env: "DB_DRIVER", flag: "db-driver", def: database.DriverSQLite, usage: "storage backend: sqlite or memory",
+		set: stringField(func(c *Config) *string { return &c.DBDriver })},
+	{env: "DB_URL", flag: "db-url", usage: "database connection string, e.g. file:rssagg.db", secret: true,
+		set: stringField(func(c *Config) *string { return &c.DBURL })},
+	{env: "ALLOWED_ORIGINS", flag: "allowed-origins", def: "https://*,http://*", usage: "comma separated CORS origins",
+		set: listField(func(c *Config) *[]string { return &c.AllowedOrigins })},
+
+	{env: "READ_HEADER_TIMEOUT", flag: "read-header-timeout", def: "5s", usage: "time allowed to read request headers",
+		set: durationField(func(c *Config) *time.Duration { return &c.ReadHeaderTimeout })},
+	{env: "READ_TIMEOUT", flag: "read-timeout", def: "10s", usage: "time allowed to read a whole request",
+		set: durationField(func(c *Config) *time.Duration { return &c.ReadTimeout })},
+	{env: "WRITE_TIMEOUT", flag: "write-timeout", def: "30s", usage: "time allowed to write a response",
+		set: durationField(func(c *Config) *time.Duration { return &c.WriteTimeout })},
+	{env: "IDLE_TIMEOUT", flag: "idle-timeout", def: "120s", usage: "how long keep-alive connections may idle",
+		set: durationField(func(c *Config) *time.Duration { return &c.IdleTimeout })},
+	{env: "SHUTDOWN_TIMEOUT", flag: "shutdown-timeout", def: "15s", usage: "how long to drain requests on shutdown",
+		set: durationField(func(c *Config) *time.Duration { return &c.ShutdownTimeout })},
+
+	{env: "SCRAPE_INTERVAL", flag: "scrape-interval", def: "1m", usage: "time between scraper runs",
+		set: durationField(func(c *Config) *time.Duration { return &c.ScrapeInterval })},
+	{env: "SCRAPE_BATCH_SIZE", flag: "scrape-batch-size", def: "10", usage: "feeds fetched per scraper run",
+		set: intField(func(c *Config) *int { return &c.ScrapeBatchSize })},
+	{env: "SCRAPE_CONCURRENCY", flag: "scrape-concurrency", def: "3", usage: "feeds fetched at the same time",
+		set: intField(func(c *Config) *int { return &c.ScrapeConcurrency })},
+	{env: "SCRAPE_MAX_FAILURES", flag: "scrape-max-failures", def: "10", usage: "failures in a row before a feed is marked dead",
+		set: intField(func(c *Config) *int { return &c.ScrapeMaxFailures })},
+	{env: "SCRAPE_MAX_BACKOFF", flag: "scrape-max-backoff", def: "24h", usage: "longest wait between retries of a failing feed",
+		set: durationField(func(c *Config) *time.Duration { return &c.ScrapeMaxBackoff })},
+	{env: "SUMMARY_LENGTH", flag: "summary-length", def: "300", usage: "characters kept in a post's plain text summary",
+		set: intField(func(c *Config) *int { return &c.SummaryLength })},
+
+	{env: "WEBHOOK_INTERVAL", flag: "webhook-interval", def: "5s", usage: "how often due webhook deliveries are sent",
+		set: durationField(func(c *Config) *time.Duration { return &c.WebhookInterval })},
+	{env: "WEBHOOK_TIMEOUT", flag: "webhook-timeout", def: "10s", usage: "time allowed for a webhook endpoint to answer",
+		set: durationField(func(c *Config) *time.Duration { return &c.WebhookTimeout })},
+	{env: "WEBHOOK_MAX_ATTEMPTS", flag: "webhook-max-attempts", def: "8", usage: "attempts before a delivery goes to the dead letter list",
+		set: intField(func(c *Config) *int { return &c.WebhookMaxAttempts })},
+	{env: "WEBHOOK_MAX_BACKOFF", flag: "webhook-max-backoff", def: "1h", usage: "longest wait between retries of a failing delivery",
+		set: durationField(func(c *Config) *time.Duration { return &c.WebhookMaxBackoff })},
+	{env: "ALLOW_PRIVATE_NETWORKS", flag: "allow-private-networks", def: "false", usage: "let feeds and webhooks use loopback and private addresses",
+		set: boolField(func(c *Config) *bool { return &c.AllowPrivateNetworks })},
+
+	{env: "RATE_LIMITS", flag: "rate-limits", def: "default=120/m,users=10/m", usage: "requests allowed per route group, e.g. default=120/m,posts=300/m",
+		set: func(c *Config, val string) error {
+			limits, err := parseRateLimits(val)
+			if err != nil {
+				return err
+			}
+			c.RateLimits = limits
+			return nil
+		}},
+}
+
+func stringField(field func(c *Config) *string) func(*Config, string) error {
+	return func(c *Config, val string) error {
+		*field(c) = val
+		return nil
+	}
+}
+
+func listField(field func(c *Config) *[]string) func(*Config, string) error {
+	return func(c *Config, val string) error {
+		items := []string{}
+		for _, item := range strings.Split(val, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		*field(c) = items
+		return nil
+	}
+}
+
+func durationField(field func(c *Config) *time.Duration) func(*Config, string) error {
+	return func(c *Config, val string) error {
+		d, err := time.ParseDuration(val)
+		if err != nil {
+			return fmt.Errorf("must be a duration like 10s")
+		}
+		if d <= 0 {
+			return fmt.Errorf("must be positive")
+		}
+		*field(c) = d
+		return nil
+	}
+}
+
+func boolField(field func(c *Config) *bool) func(*Config, string) error {
+	return func(c *Config, val string) error {
+		b, err := strconv.ParseBool(val)
+		if err != nil {
+			return fmt.Errorf("must be true or false")
+		}
+		*field(c) = b
+		return nil
+	}
+}
+
+func intField(field func(c *Config) *int) func(*Config, string) error {
+	return func(c *Config, val string) error {
+		n, err := strconv.Atoi(val)
+		if err != nil {
+			return fmt.Errorf("must be an integer")
+		}
+		if n < 1 {
+			return fmt.Errorf("must be at least 1")
+		}
+		*field(c) = n
+		return nil
+	}
+}
+
+// loadConfig resolves every setting from, lowest priority first, the
+// defaults, a .env file, the environment and the command line flags in args.
+// The returned error lists every invalid setting, not just the first.
+func loadConfig(args []string) (Config, error) {
+	// doesn't override variables that are already set in the environment
+	godotenv.Load()
+
+	values := map[string]string{}
+	for _, v := range configVars {
+		values[v.env] = v.def
+		if envVal, ok := os.LookupEnv(v.env); ok {
+			values[v.env] = envVal
+		}
+	}
+
+	cfg := Config{}
+	fs := flag.NewFlagSet("rssagg", flag.ContinueOnError)
+	flagValues := map[string]*string{}
+	for _, v := range configVars {
+		flagValues[v.env] = fs.String(v.flag, values[v.env], fmt.Sprintf("%s (env %s)", v.usage, v.env))
+	}
+	fs.BoolVar(&cfg.PrintConfig, "print-config", false, "print the resolved config with secrets redacted and exit")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: rssagg [flags] [command]\n\nCommands:\n")
+		printCommands(fs.Output())
+		fmt.Fprintf(fs.Output(), "\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return Config{}, err
+	}
+	// flags shared by every command come before it, the rest is the command's
+	cfg.Command = commandServe
+	if fs.NArg() > 0 {
+		cfg.Command = fs.Arg(0)
+		cfg.Args = fs.Args()[1:]
+	}
+
+	errs := []error{}
+	for _, v := range configVars {
+		values[v.env] = *flagValues[v.env]
+		if err := v.set(&cfg, values[v.env]); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", v.env, err))
+		}
+	}
+	errs = append(errs, cfg.validate()...)
+
+	cfg.resolved = values
+	return cfg, errors.Join(errs...)
+}
+
+// validate checks the rules that involve more than one field or that the
+// field parsers can't know about
+func (cfg Config) validate() []error {
+	errs := []error{}
+	if _, ok := commands[cfg.Command]; !ok {
+		errs = append(errs, fmt.Errorf("unknown command %q, run rssagg -h for the list", cfg.Command))
+	}
+
+	// only serve listens, the other commands just need storage
+	if cfg.Port == "" {
+		if cfg.Command == commandServe {
+			errs = append(errs, errors.New("PORT: is required"))
+		}
+	} else if port, err := strconv.Atoi(cfg.Port); err != nil || port < 1 || port > 65535 {
+		errs = append(errs, errors.New("PORT: must be a number between 1 and 65535"))
+	}
+
+	switch cfg.DBDriver {
+	case database.DriverSQLite:
+		if cfg.DBURL == "" {
+			errs = append(errs, fmt.Errorf("DB_URL: is required when DB_DRIVER is %s", cfg.DBDriver))
+		}
+	case database.DriverMemory:
+	default:
+		errs = append(errs, fmt.Errorf("DB_DRIVER: must be %s or %s", database.DriverSQLite, database.DriverMemory))
+	}
+
+	if len(cfg.AllowedOrigins) == 0 {
+		errs = append(errs, errors.New("ALLOWED_ORIGINS: needs at least one origin"))
+	}
+	return errs
+}
+
+// print writes the resolved settings in .env format. Secrets are
+// redacted so the output is safe to paste into a bug report.
+func (cfg Config) print(w io.Writer) {
+	for _, v := range configVars {
+		val := cfg.resolved[v.env]
+		if v.secret && val != "" {
+			val = "REDACTED"
+		}
+		fmt.Fprintf(w, "%s=%s\n", v.env, val)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/anishakd4/rssagg/internal/database"
+	"github.com/go-chi/chi"
+	"github.com/google/uuid"
+)
+
+func (apiCfg *apiConfig) handler_create_feed_follow(w http.ResponseWriter, r *http.Request, user database.User) {
+	type parameters struct {
+		FeedID uuid.UUID `json:"feed_id"`
+	}
+	params := parameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, r, 400, fmt.Sprintf("Error parsing JSON: %v", err))
+		return
+	}
+
+	now := time.Now().UTC()
+	feedFollow, err := apiCfg.DB.CreateFeedFollow(r.Context(), database.CreateFeedFollowParams{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		UserID:    user.ID,
+		FeedID:    params.FeedID,
+	})
+	if database.IsUniqueViolation(err) {
+		respondWithError(w, r, 409, "Already following that feed")
+		return
+	}
+	if database.IsForeignKeyViolation(err) {
+		respondWithError(w, r, 404, "Couldn't find feed")
+		return
+	}
+	if err != nil {
+		respondWithError(w, r, 500, fmt.Sprintf("Couldn't create feed follow: %v", err))
+		return
+	}
+
+	respondWithJSON(w, r, 201, databaseFeedFollowToFeedFollow(feedFollow))
+}
+
+// handler_get_feed_follows lists the user's follows, oldest first unless
+// sort=-created_at. feed_id and since filter them.
+func (apiCfg *apiConfig) handler_get_feed_follows(w http.ResponseWriter, r *http.Request, user database.User) {
+	params, err := parseListParams(r, "created_at", "created_at")
+	if err != nil {
+		respondWithError(w, r, 400, err.Error())
+		return
+	}
+	feedID, err := parseUUIDQuery(r, "feed_id")
+	if err != nil {
+		respondWithError(w, r, 400, err.Error())
+		return
+	}
+	since, err := parseTimeQuery(r, "since")
+	if err != nil {
+		respondWithError(w, r, 400, err.Error())
+		return
+	}
+
+	feedFollows, err := apiCfg.DB.GetFeedFollows(r.Context(), database.GetFeedFollowsParams{
+		UserID: user.ID,
+		FeedID: feedID,
+		Since:  since,
+		After:  params.after,
+		Desc:   params.desc,
+		Limit:  params.fetchLimit(),
+	})
+	if err != nil {
+		respondWithError(w, r, 500, fmt.Sprintf("Couldn't get feed follows: %v", err))
+		return
+	}
+
+	feedFollows, next := page(params, feedFollows, func(feedFollow database.FeedFollow) (time.Time, uuid.UUID) {
+		return feedFollow.CreatedAt, feedFollow.ID
+	})
+	respondWithList(w, r, 200, databaseFeedFollowsToFeedFollows(feedFollows), next)
+}
+
+func (apiCfg *apiConfig) handler_delete_feed_follow(w http.ResponseWriter, r *http.Request, user database.User) {
+	feedFollowID, err := uuid.Parse(chi.URLParam(r, "feedFollowID"))
+	if err != nil {
+		respondWithError(w, r, 400, fmt.Sprintf("Couldn't parse feed follow id: %v", err))
+		return
+	}
+
+	err = apiCfg.DB.DeleteFeedFollow(r.Context(), database.DeleteFeedFollowParams{
+		ID:     feedFollowID,
+		UserID: user.ID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, r, 404, "Couldn't find feed follow")
+		return
+	}
+	if err != nil {
+		respondWithError(w, r, 500, fmt.Sprintf("Couldn't delete feed follow: %v", err))
+		return
+	}
+
+	respondWithJSON(w, r, 200, struct{}{})
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/anishakd4/rssagg/internal/database"
+	"github.com/anishakd4/rssagg/internal/discover"
+	"github.com/go-chi/chi"
+	"github.com/google/uuid"
+)
+
+// discoverTimeout bounds the requests made looking for a feed, which can be
+// several when the url is a web page
+const discoverTimeout = 20 * time.Second
+
+// handler_create_feed takes either a feed url or the url of a page that leads
+// to one, see discover.Find. Without a name the feed's title is used.
+func (apiCfg *apiConfig) handler_create_feed(w http.ResponseWriter, r *http.Request, user database.User) {
+	type parameters struct {
+		Name string `json:"name"`
+		URL  string `json:"url"`
+	}
+	type response struct {
+		Feed       Feed       `json:"feed"`
+		FeedFollow FeedFollow `json:"feed_follow"`
+	}
+	params := parameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, r, 400, fmt.Sprintf("Error parsing JSON: %v", err))
+		return
+	}
+	if !isFeedURL(params.URL) {
+		respondWithError(w, r, 400, "url must be an absolute http(s) URL")
+		return
+	}
+
+	// no need to go looking for a feed we already have
+	if _, err := apiCfg.DB.GetFeedByURL(r.Context(), params.URL); err == nil {
+		respondWithError(w, r, 409, "A feed with that url already exists")
+		return
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, r, 500, fmt.Sprintf("Couldn't get feed: %v", err))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), discoverTimeout)
+	defer cancel()
+	found, err := discover.Find(ctx, apiCfg.FetchClient, params.URL)
+	if err != nil {
+		// what went wrong upstream stays in the log, echoing it would tell
+		// the caller which hosts and ports answer
+		log.Printf("Couldn't find a feed at %s: %v", params.URL, err)
+		respondWithError(w, r, 422, "Couldn't find a feed at url")
+		return
+	}
+	if params.Name == "" {
+		params.Name = strings.TrimSpace(found.Feed.Title)
+	}
+	if params.Name == "" {
+		params.Name = found.URL
+	}
+
+	now := time.Now().UTC()
+	feed, err := apiCfg.DB.CreateFeed(r.Context(), database.CreateFeedParams{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		Name:      params.Name,
+		Url:       found.URL,
+		UserID:    user.ID,
+	})
+	if database.IsUniqueViolation(err) {
+		respondWithError(w, r, 409, "A feed with that url already exists")
+		return
+	}
+	if err != nil {
+		respondWithError(w, r, 500, fmt.Sprintf("Couldn't create feed: %v", err))
+		return
+	}
+
+	// whoever adds a feed is subscribed to it
+	feedFollow, err := apiCfg.DB.CreateFeedFollow(r.Context(), database.CreateFeedFollowParams{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		UserID:    user.ID,
+		FeedID:    feed.ID,
+	})
+	if err != nil {
+		respondWithError(w, r, 500, fmt.Sprintf("Couldn't create feed follow: %v", err))
+		return
+	}
+
+	respondWithJSON(w, r, 201, response{
+		Feed:       databaseFeedToFeed(feed),
+		FeedFollow: databaseFeedFollowToFeedFollow(feedFollow),
+	})
+}
+
+// handler_get_feeds lists every feed, oldest first unless sort=-created_at.
+// q filters by name and since by when the feed was added.
+func (apiCfg *apiConfig) handler_get_feeds(w http.ResponseWriter, r *http.Request) {
+	params, err := parseListParams(r, "created_at", "created_at")
+	if err != nil {
+		respondWithError(w, r, 400, err.Error())
+		return
+	}
+	since, err := parseTimeQuery(r, "since")
+	if err != nil {
+		respondWithError(w, r, 400, err.Error())
+		return
+	}
+
+	feeds, err := apiCfg.DB.GetFeeds(r.Context(), database.GetFeedsParams{
+		Query: r.URL.Query().Get("q"),
+		Since: since,
+		After: params.after,
+		Desc:  params.desc,
+		Limit: params.fetchLimit(),
+	})
+	if err != nil {
+		respondWithError(w, r, 500, fmt.Sprintf("Couldn't get feeds: %v", err))
+		return
+	}
+
+	feeds, next := page(params, feeds, func(feed database.Feed) (time.Time, uuid.UUID) {
+		return feed.CreatedAt, feed.ID
+	})
+	respondWithList(w, r, 200, databaseFeedsToFeeds(feeds), next)
+}
+
+func (apiCfg *apiConfig) handler_get_feed(w http.ResponseWriter, r *http.Request) {
+	feedID, err := uuid.Parse(chi.URLParam(r, "feedID"))
+	if err != nil {
+		respondWithError(w, r, 400, fmt.Sprintf("Couldn't parse feed id: %v", err))
+		return
+	}
+
+	feed, err := apiCfg.DB.GetFeedByID(r.Context(), feedID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, r, 404, "Couldn't find feed")
+		return
+	}
+	if err != nil {
+		respondWithError(w, r, 500, fmt.Sprintf("Couldn't get feed: %v", err))
+		return
+	}
+
+	respondWithJSON(w, r, 200, databaseFeedToFeed(feed))
+}
+
+// isFeedURL reports whether s is something the scraper can fetch
+func isFeedURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/anishakd4/rssagg/internal/database"
+	"github.com/anishakd4/rssagg/internal/opml"
+	"github.com/google/uuid"
+)
+
+// maxOPMLSize is generous, a few thousand subscriptions is well under 1MB
+const maxOPMLSize = 5 << 20
+
+func (apiCfg *apiConfig) handler_import_opml(w http.ResponseWriter, r *http.Request, user database.User) {
+	type failure struct {
+		URL   string `json:"url"`
+		Error string `json:"error"`
+	}
+	type response struct {
+		FeedsCreated     int       `json:"feeds_created"`
+		FeedsFollowed    int       `json:"feeds_followed"`
+		AlreadyFollowing int       `json:"already_following"`
+		Failed           []failure `json:"failed"`
+	}
+
+	doc, err := opml.Parse(http.MaxBytesReader(w, r.Body, maxOPMLSize))
+	if err != nil {
+		respondWithError(w, r, 400, fmt.Sprintf("Error parsing OPML: %v", err))
+		return
+	}
+
+	resp := response{Failed: []failure{}}
+	for _, outline := range doc.Feeds() {
+		if !isFeedURL(outline.XMLURL) {
+			resp.Failed = append(resp.Failed, failure{URL: outline.XMLURL, Error: "not an absolute http(s) URL"})
+			continue
+		}
+
+		feed, created, err := apiCfg.getOrCreateFeed(r, user, outline.Name(), outline.XMLURL)
+		if err != nil {
+			log.Printf("Couldn't import feed %s: %v", outline.XMLURL, err)
+			resp.Failed = append(resp.Failed, failure{URL: outline.XMLURL, Error: "couldn't create feed"})
+			continue
+		}
+		if created {
+			resp.FeedsCreated++
+		}
+
+		now := time.Now().UTC()
+		_, err = apiCfg.DB.CreateFeedFollow(r.Context(), database.CreateFeedFollowParams{
+			ID:        uuid.New(),
+			CreatedAt: now,
+			UpdatedAt: now,
+			UserID:    user.ID,
+			FeedID:    feed.ID,
+		})
+		if database.IsUniqueViolation(err) {
+			resp.AlreadyFollowing++
+			continue
+		}
+		if err != nil {
+			log.Printf("Couldn't follow feed %s: %v", outline.XMLURL, err)
+			resp.Failed = append(resp.Failed, failure{URL: outline.XMLURL, Error: "couldn't follow feed"})
+			continue
+		}
+		resp.FeedsFollowed++
+	}
+
+	respondWithJSON(w, r, 200, resp)
+}
+
+// getOrCreateFeed reuses the feed if someone already added the url
+func (apiCfg *apiConfig) getOrCreateFeed(r *http.Request, user database.User, name, feedURL string) (database.Feed, bool, error) {
+	feed, err := apiCfg.DB.GetFeedByURL(r.Context(), feedURL)
+	if err == nil {
+		return feed, false, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return database.Feed{}, false, err
+	}
+
+	now := time.Now().UTC()
+	feed, err = apiCfg.DB.CreateFeed(r.Context(), database.CreateFeedParams{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		Name:      name,
+		Url:       feedURL,
+		UserID:    user.ID,
+	})
+	// lost a race with another import of the same url
+	if database.IsUniqueViolation(err) {
+		feed, err = apiCfg.DB.GetFeedByURL(r.Context(), feedURL)
+		return feed, false, err
+	}
+	return feed, err == nil, err
+}
+
+func (apiCfg *apiConfig) handler_export_opml(w http.ResponseWriter, r *http.Request, user database.User) {
+	feeds, err := apiCfg.DB.GetFeedsForUser(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, r, 500, fmt.Sprintf("Couldn't get feeds: %v", err))
+		return
+	}
+
+	outlines := []opml.Outline{}
+	for _, feed := range feeds {
+		outlines = append(outlines, opml.Outline{
+			Text:   feed.Name,
+			Title:  feed.Name,
+			Type:   "rss",
+			XMLURL: feed.Url,
+		})
+	}
+	doc := opml.New(fmt.Sprintf("%s's subscriptions", user.Name), outlines)
+
+	w.Header().Set("Content-Type", "text/x-opml; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="subscriptions.opml"`)
+	w.WriteHeader(200)
+	if err := doc.Write(w); err != nil {
+		log.Println("Couldn't write OPML response:", err)
+	}
+}
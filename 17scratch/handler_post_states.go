@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/anishakd4/rssagg/internal/database"
+	"github.com/go-chi/chi"
+	"github.com/google/uuid"
+)
+
+func (apiCfg *apiConfig) handler_mark_post_read(w http.ResponseWriter, r *http.Request, user database.User) {
+	apiCfg.setPostState(w, r, user, func(ctx context.Context, post database.Post) (database.PostState, error) {
+		return apiCfg.DB.SetPostRead(ctx, database.SetPostReadParams{UserID: user.ID, PostID: post.ID, Read: true})
+	})
+}
+
+func (apiCfg *apiConfig) handler_mark_post_unread(w http.ResponseWriter, r *http.Request, user database.User) {
+	apiCfg.setPostState(w, r, user, func(ctx context.Context, post database.Post) (database.PostState, error) {
+		return apiCfg.DB.SetPostRead(ctx, database.SetPostReadParams{UserID: user.ID, PostID: post.ID, Read: false})
+	})
+}
+
+// handler_star_post saves a post for later, starred posts are listed with
+// GET /posts?state=starred
+func (apiCfg *apiConfig) handler_star_post(w http.ResponseWriter, r *http.Request, user database.User) {
+	apiCfg.setPostState(w, r, user, func(ctx context.Context, post database.Post) (database.PostState, error) {
+		return apiCfg.DB.SetPostStarred(ctx, database.SetPostStarredParams{UserID: user.ID, PostID: post.ID, Starred: true})
+	})
+}
+
+func (apiCfg *apiConfig) handler_unstar_post(w http.ResponseWriter, r *http.Request, user database.User) {
+	apiCfg.setPostState(w, r, user, func(ctx context.Context, post database.Post) (database.PostState, error) {
+		return apiCfg.DB.SetPostStarred(ctx, database.SetPostStarredParams{UserID: user.ID, PostID: post.ID, Starred: false})
+	})
+}
+
+// setPostState applies set to the post in the url and responds with the post
+// and its new state. Users can only change posts from feeds they follow.
+func (apiCfg *apiConfig) setPostState(w http.ResponseWriter, r *http.Request, user database.User, set func(ctx context.Context, post database.Post) (database.PostState, error)) {
+	postID, err := uuid.Parse(chi.URLParam(r, "postID"))
+	if err != nil {
+		respondWithError(w, r, 400, fmt.Sprintf("Couldn't parse post id: %v", err))
+		return
+	}
+
+	post, err := apiCfg.DB.GetPostByID(r.Context(), postID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, r, 500, fmt.Sprintf("Couldn't get post: %v", err))
+		return
+	}
+	followed, followedErr := apiCfg.followedFeedIDs(r, user)
+	if followedErr != nil {
+		respondWithError(w, r, 500, fmt.Sprintf("Couldn't get followed feeds: %v", followedErr))
+		return
+	}
+	if errors.Is(err, sql.ErrNoRows) || !followed[post.FeedID] {
+		respondWithError(w, r, 404, "Couldn't find post")
+		return
+	}
+
+	state, err := set(r.Context(), post)
+	if err != nil {
+		respondWithError(w, r, 500, fmt.Sprintf("Couldn't update post: %v", err))
+		return
+	}
+
+	respondWithJSON(w, r, 200, databasePostToPost(post).withState(state))
+}
+
+// handler_mark_posts_read marks every unread post from the feeds the user
+// follows read, or only those of feed_id and those published before before
+func (apiCfg *apiConfig) handler_mark_posts_read(w http.ResponseWriter, r *http.Request, user database.User) {
+	type response struct {
+		Marked int64 `json:"marked"`
+	}
+	feedID, err := parseUUIDQuery(r, "feed_id")
+	if err != nil {
+		respondWithError(w, r, 400, err.Error())
+		return
+	}
+	before, err := parseTimeQuery(r, "before")
+	if err != nil {
+		respondWithError(w, r, 400, err.Error())
+		return
+	}
+
+	marked, err := apiCfg.DB.MarkPostsRead(r.Context(), database.MarkPostsReadParams{
+		UserID: user.ID,
+		FeedID: feedID,
+		Before: before,
+	})
+	if err != nil {
+		respondWithError(w, r, 500, fmt.Sprintf("Couldn't mark posts read: %v", err))
+		return
+	}
+
+	respondWithJSON(w, r, 200, response{Marked: marked})
+}
+
+// postsWithStates converts posts for a response, filling in the user's read
+// and starred state of each
+func postsWithStates(ctx context.Context, db database.Store, userID uuid.UUID, dbPosts []database.Post) ([]Post, error) {
+	postIDs := make([]uuid.UUID, 0, len(dbPosts))
+	for _, dbPost := range dbPosts {
+		postIDs = append(postIDs, dbPost.ID)
+	}
+	states, err := db.GetPostStates(ctx, database.GetPostStatesParams{
+		UserID:  userID,
+		PostIDs: postIDs,
+	})
+	if err != nil {
+		return nil, err
+	}
+	byPost := map[uuid.UUID]database.PostState{}
+	for _, state := range states {
+		byPost[state.PostID] = state
+	}
+
+	posts := []Post{}
+	for _, dbPost := range dbPosts {
+		posts = append(posts, databasePostToPost(dbPost).withState(byPost[dbPost.ID]))
+	}
+	return posts, nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/anishakd4/rssagg/internal/database"
+	"github.com/google/uuid"
+)
+
+// handler_get_posts_for_user lists posts from the feeds the user follows,
+// newest first unless sort=published_at. feed_id, since, before, a q title
+// search and state (unread, read or starred) filter them.
+func (apiCfg *apiConfig) handler_get_posts_for_user(w http.ResponseWriter, r *http.Request, user database.User) {
+	params, err := parseListParams(r, "published_at", "-published_at")
+	if err != nil {
+		respondWithError(w, r, 400, err.Error())
+		return
+	}
+	feedID, err := parseUUIDQuery(r, "feed_id")
+	if err != nil {
+		respondWithError(w, r, 400, err.Error())
+		return
+	}
+	since, err := parseTimeQuery(r, "since")
+	if err != nil {
+		respondWithError(w, r, 400, err.Error())
+		return
+	}
+	before, err := parseTimeQuery(r, "before")
+	if err != nil {
+		respondWithError(w, r, 400, err.Error())
+		return
+	}
+	state := r.URL.Query().Get("state")
+	switch state {
+	case "", database.PostStateUnread, database.PostStateRead, database.PostStateStarred:
+	default:
+		respondWithError(w, r, 400, fmt.Sprintf("state must be %s, %s or %s", database.PostStateUnread, database.PostStateRead, database.PostStateStarred))
+		return
+	}
+
+	posts, err := apiCfg.DB.GetPostsForUser(r.Context(), database.GetPostsForUserParams{
+		UserID: user.ID,
+		FeedID: feedID,
+		Since:  since,
+		Before: before,
+		Query:  r.URL.Query().Get("q"),
+		State:  state,
+		After:  params.after,
+		Desc:   params.desc,
+		Limit:  params.fetchLimit(),
+	})
+	if err != nil {
+		respondWithError(w, r, 500, fmt.Sprintf("Couldn't get posts: %v", err))
+		return
+	}
+
+	posts, next := page(params, posts, func(post database.Post) (time.Time, uuid.UUID) {
+		return post.PublishedAt, post.ID
+	})
+	resp, err := postsWithStates(r.Context(), apiCfg.DB, user.ID, posts)
+	if err != nil {
+		respondWithError(w, r, 500, fmt.Sprintf("Couldn't get post states: %v", err))
+		return
+	}
+	respondWithList(w, r, 200, resp, next)
+}
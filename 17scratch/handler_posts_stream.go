@@ -0,0 +1,186 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/anishakd4/rssagg/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	// streamKeepAlive is how often an idle stream gets a comment so proxies
+	// don't time it out. The followed feeds are refreshed at the same time.
+	streamKeepAlive = 15 * time.Second
+	// streamReplayBatch is how many missed posts are read from storage at a
+	// time while a reconnecting client catches up
+	streamReplayBatch = 500
+)
+
+// handler_stream_posts sends new posts from followed feeds as Server-Sent
+// Events. A client that reconnects with Last-Event-ID is first sent every
+// post stored since that one.
+func (apiCfg *apiConfig) handler_stream_posts(w http.ResponseWriter, r *http.Request, user database.User) {
+	rc := http.NewResponseController(w)
+	// the server's WriteTimeout would cut the stream off
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		respondWithError(w, r, 500, fmt.Sprintf("Couldn't start stream: %v", err))
+		return
+	}
+
+	followed, err := apiCfg.followedFeedIDs(r, user)
+	if err != nil {
+		respondWithError(w, r, 500, fmt.Sprintf("Couldn't get feed follows: %v", err))
+		return
+	}
+
+	// subscribe before replaying so nothing is missed in between
+	posts, unsubscribe := apiCfg.Broker.subscribe()
+	defer unsubscribe()
+
+	replayFrom := database.Cursor{}
+	if lastEventID := r.Header.Get("Last-Event-ID"); lastEventID != "" {
+		replayFrom, err = apiCfg.lastEventCursor(r, lastEventID)
+		if err != nil {
+			respondWithError(w, r, 500, fmt.Sprintf("Couldn't get missed posts: %v", err))
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(200)
+
+	sent := map[uuid.UUID]bool{}
+	if replayFrom.Valid {
+		// the client picks up from the last post it got when it reconnects
+		if err := apiCfg.replayPosts(w, r, rc, user, replayFrom, sent); err != nil {
+			log.Println("Couldn't replay missed posts:", err)
+			return
+		}
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	ticker := time.NewTicker(streamKeepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+			if refreshed, err := apiCfg.followedFeedIDs(r, user); err == nil {
+				followed = refreshed
+			} else {
+				log.Println("Couldn't refresh feed follows for stream:", err)
+			}
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+		case post, ok := <-posts:
+			// dropped for falling behind or the server is shutting down,
+			// the client reconnects with Last-Event-ID
+			if !ok {
+				return
+			}
+			if sent[post.ID] {
+				continue
+			}
+			// a feed we haven't seen may have been followed since the last
+			// refresh. Unfollowed feeds stay false until the next tick.
+			if _, seen := followed[post.FeedID]; !seen {
+				if refreshed, err := apiCfg.followedFeedIDs(r, user); err == nil {
+					followed = refreshed
+				}
+				if !followed[post.FeedID] {
+					followed[post.FeedID] = false
+				}
+			}
+			if !followed[post.FeedID] {
+				continue
+			}
+			if err := writePostEvent(w, post); err != nil {
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func writePostEvent(w http.ResponseWriter, post database.Post) error {
+	data, err := json.Marshal(databasePostToPost(post))
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %s\nevent: post\ndata: %s\n\n", post.ID, data)
+	return err
+}
+
+func (apiCfg *apiConfig) followedFeedIDs(r *http.Request, user database.User) (map[uuid.UUID]bool, error) {
+	feeds, err := apiCfg.DB.GetFeedsForUser(r.Context(), user.ID)
+	if err != nil {
+		return nil, err
+	}
+	followed := map[uuid.UUID]bool{}
+	for _, feed := range feeds {
+		followed[feed.ID] = true
+	}
+	return followed, nil
+}
+
+// lastEventCursor is where to replay from for a client whose last post was
+// lastEventID. An id we don't know replays nothing.
+func (apiCfg *apiConfig) lastEventCursor(r *http.Request, lastEventID string) (database.Cursor, error) {
+	id, err := uuid.Parse(lastEventID)
+	if err != nil {
+		return database.Cursor{}, nil
+	}
+	last, err := apiCfg.DB.GetPostByID(r.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.Cursor{}, nil
+	}
+	if err != nil {
+		return database.Cursor{}, err
+	}
+	return database.Cursor{Time: last.CreatedAt, ID: last.ID, Valid: true}, nil
+}
+
+// replayPosts sends the user's posts stored after the after cursor, oldest
+// first. They're read a batch at a time, so a client that's far behind gets
+// all of them without holding them in memory at once.
+func (apiCfg *apiConfig) replayPosts(w http.ResponseWriter, r *http.Request, rc *http.ResponseController, user database.User, after database.Cursor, sent map[uuid.UUID]bool) error {
+	for {
+		posts, err := apiCfg.DB.GetPostsForUserCreatedAfter(r.Context(), database.GetPostsForUserCreatedAfterParams{
+			UserID: user.ID,
+			After:  after,
+			Limit:  streamReplayBatch,
+		})
+		if err != nil {
+			return err
+		}
+		for _, post := range posts {
+			if err := writePostEvent(w, post); err != nil {
+				return err
+			}
+			sent[post.ID] = true
+		}
+		if err := rc.Flush(); err != nil {
+			return err
+		}
+		if len(posts) < streamReplayBatch {
+			return nil
+		}
+		last := posts[len(posts)-1]
+		after = database.Cursor{Time: last.CreatedAt, ID: last.ID, Valid: true}
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anishakd4/rssagg/internal/database"
+	"github.com/google/uuid"
+)
+
+func TestStreamReplaysEveryMissedPost(t *testing.T) {
+	ctx := context.Background()
+	store := database.NewMemoryStore()
+	user := createTestUser(t, store)
+	feed := createTestFeed(t, store, user, "https://example.com/feed.xml")
+	now := time.Now().UTC()
+	if _, err := store.CreateFeedFollow(ctx, database.CreateFeedFollowParams{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		UserID:    user.ID,
+		FeedID:    feed.ID,
+	}); err != nil {
+		t.Fatalf("CreateFeedFollow: %v", err)
+	}
+
+	// more than two batches behind, with some posts stored at the same time
+	// across a batch boundary
+	total := 2*streamReplayBatch + 7
+	ids := []uuid.UUID{}
+	for i := 0; i < total; i++ {
+		createdAt := now.Add(time.Duration((i+2)/3) * time.Millisecond)
+		post, err := store.CreatePost(ctx, database.CreatePostParams{
+			ID:          uuid.New(),
+			CreatedAt:   createdAt,
+			UpdatedAt:   createdAt,
+			Title:       fmt.Sprintf("Post %d", i),
+			Url:         fmt.Sprintf("https://example.com/%d", i),
+			PublishedAt: createdAt,
+			Guid:        fmt.Sprint(i),
+			FeedID:      feed.ID,
+		})
+		if err != nil {
+			t.Fatalf("CreatePost: %v", err)
+		}
+		ids = append(ids, post.ID)
+	}
+
+	apiCfg := &apiConfig{DB: store, Broker: newPostBroker()}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		apiCfg.handler_stream_posts(w, r, user)
+	}))
+	defer srv.Close()
+
+	// a client whose last post was the first one
+	reqCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(reqCtx, "GET", srv.URL, nil)
+	req.Header.Set("Last-Event-ID", ids[0].String())
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET stream: %v", err)
+	}
+	defer resp.Body.Close()
+
+	got := map[uuid.UUID]bool{}
+	scanner := bufio.NewScanner(resp.Body)
+	for len(got) < total-1 && scanner.Scan() {
+		line, ok := strings.CutPrefix(scanner.Text(), "id: ")
+		if !ok {
+			continue
+		}
+		id := uuid.MustParse(line)
+		if got[id] {
+			t.Fatalf("post %s replayed twice", id)
+		}
+		got[id] = true
+	}
+	if len(got) != total-1 {
+		t.Fatalf("replayed %d posts, want %d: %v", len(got), total-1, scanner.Err())
+	}
+	for _, id := range ids[1:] {
+		if !got[id] {
+			t.Errorf("post %s wasn't replayed", id)
+		}
+	}
+}
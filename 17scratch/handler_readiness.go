@@ -2,10 +2,12 @@ package main
 
 import "net/http"
 
-func handler_readiness(w http.ResponseWriter, r *http.Request){
-	respondWithJSON(w, 200, struct{}{})
+// handler_livez only says the process is up and serving, dependencies are
+// checked by /readyz
+func handler_livez(w http.ResponseWriter, r *http.Request){
+	respondWithJSON(w, r, 200, healthStatus{Status: healthOK})
 }
 
 func handler_err(w http.ResponseWriter, r *http.Request){
-	respondWithError(w, 400, "something went wrong")
+	respondWithError(w, r, 400, "something went wrong")
 }
\ No newline at end of file
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/anishakd4/rssagg/internal/database"
+	"github.com/anishakd4/rssagg/internal/search"
+	"github.com/google/uuid"
+)
+
+// searchCursor is what a search's opaque cursor decodes to. Results are
+// ranked rather than sorted by a field, so it's an offset, tied to the query
+// it was made for.
+type searchCursor struct {
+	Query  string `json:"q"`
+	Offset int    `json:"o"`
+}
+
+func encodeSearchCursor(query string, offset int) string {
+	data, _ := json.Marshal(searchCursor{Query: query, Offset: offset})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func parseSearchCursor(r *http.Request, query string) (int, error) {
+	cursor := r.URL.Query().Get("cursor")
+	if cursor == "" {
+		return 0, nil
+	}
+	token := searchCursor{}
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err == nil {
+		err = json.Unmarshal(data, &token)
+	}
+	if err != nil || token.Offset < 0 {
+		return 0, errors.New("cursor is invalid")
+	}
+	if token.Query != query {
+		return 0, errors.New("cursor was made for a different q")
+	}
+	return token.Offset, nil
+}
+
+// handler_search searches the titles and descriptions of posts from the
+// feeds the user follows, best match first. Words in q must all match and
+// "quoted phrases" must match as written.
+func (apiCfg *apiConfig) handler_search(w http.ResponseWriter, r *http.Request, user database.User) {
+	q := r.URL.Query().Get("q")
+	query, err := search.ParseQuery(q)
+	if err != nil {
+		respondWithError(w, r, 400, fmt.Sprintf("q: %v", err))
+		return
+	}
+	limit, err := parseLimit(r)
+	if err != nil {
+		respondWithError(w, r, 400, err.Error())
+		return
+	}
+	offset, err := parseSearchCursor(r, q)
+	if err != nil {
+		respondWithError(w, r, 400, err.Error())
+		return
+	}
+	feedID, err := parseUUIDQuery(r, "feed_id")
+	if err != nil {
+		respondWithError(w, r, 400, err.Error())
+		return
+	}
+
+	followed, err := apiCfg.followedFeedIDs(r, user)
+	if err != nil {
+		respondWithError(w, r, 500, fmt.Sprintf("Couldn't get followed feeds: %v", err))
+		return
+	}
+
+	results := apiCfg.Search.Search(search.SearchParams{
+		Query: query,
+		Filter: func(id uuid.UUID) bool {
+			return followed[id] && (!feedID.Valid || id == feedID.UUID)
+		},
+		Offset: offset,
+		// one extra tells us whether there's another page
+		Limit: limit + 1,
+	})
+	next := ""
+	if len(results) > limit {
+		results = results[:limit]
+		next = encodeSearchCursor(q, offset+limit)
+	}
+
+	posts := []database.Post{}
+	found := []search.Result{}
+	for _, result := range results {
+		post, err := apiCfg.DB.GetPostByID(r.Context(), result.ID)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			respondWithError(w, r, 500, fmt.Sprintf("Couldn't get post: %v", err))
+			return
+		}
+		posts = append(posts, post)
+		found = append(found, result)
+	}
+	withStates, err := postsWithStates(r.Context(), apiCfg.DB, user.ID, posts)
+	if err != nil {
+		respondWithError(w, r, 500, fmt.Sprintf("Couldn't get post states: %v", err))
+		return
+	}
+
+	resp := []SearchResult{}
+	for i, result := range found {
+		resp = append(resp, SearchResult{
+			Post:    withStates[i],
+			Score:   result.Score,
+			Title:   result.Title,
+			Snippet: result.Snippet,
+		})
+	}
+	respondWithList(w, r, 200, resp, next)
+}
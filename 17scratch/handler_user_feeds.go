@@ -0,0 +1,208 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/anishakd4/rssagg/internal/database"
+	"github.com/go-chi/chi"
+	"github.com/google/uuid"
+)
+
+// userFeedSize is how many of the newest posts a published timeline holds
+const userFeedSize = 50
+
+type jsonFeedDoc struct {
+	Version     string           `json:"version"`
+	Title       string           `json:"title"`
+	FeedURL     string           `json:"feed_url"`
+	Description string           `json:"description"`
+	Authors     []jsonFeedAuthor `json:"authors"`
+	Items       []jsonFeedItem   `json:"items"`
+}
+
+type jsonFeedAuthor struct {
+	Name string `json:"name"`
+}
+
+type jsonFeedItem struct {
+	ID            string    `json:"id"`
+	URL           string    `json:"url,omitempty"`
+	Title         string    `json:"title,omitempty"`
+	ContentHTML   string    `json:"content_html"`
+	Summary       string    `json:"summary,omitempty"`
+	DatePublished time.Time `json:"date_published"`
+}
+
+type atomFeedDoc struct {
+	XMLName xml.Name       `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string         `xml:"title"`
+	ID      string         `xml:"id"`
+	Updated string         `xml:"updated"`
+	Link    []atomLinkDoc  `xml:"link"`
+	Author  atomAuthorDoc  `xml:"author"`
+	Entries []atomEntryDoc `xml:"entry"`
+}
+
+type atomLinkDoc struct {
+	Rel  string `xml:"rel,attr"`
+	Href string `xml:"href,attr"`
+}
+
+type atomAuthorDoc struct {
+	Name string `xml:"name"`
+}
+
+type atomEntryDoc struct {
+	Title     string         `xml:"title"`
+	ID        string         `xml:"id"`
+	Link      *atomLinkDoc   `xml:"link,omitempty"`
+	Published string         `xml:"published"`
+	Updated   string         `xml:"updated"`
+	Summary   string         `xml:"summary,omitempty"`
+	Content   atomContentDoc `xml:"content"`
+}
+
+type atomContentDoc struct {
+	Type string `xml:"type,attr"`
+	Text string `xml:",chardata"`
+}
+
+// handler_get_user_json_feed publishes the newest posts of a user's timeline
+// as a JSON Feed 1.1, so it can be followed from another reader. Readers
+// can't send an api key, so like the Atom version it takes the user's feed
+// token in the url instead.
+func (apiCfg *apiConfig) handler_get_user_json_feed(w http.ResponseWriter, r *http.Request) {
+	user, posts, ok := apiCfg.userTimeline(w, r)
+	if !ok {
+		return
+	}
+
+	doc := jsonFeedDoc{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       userFeedTitle(user),
+		FeedURL:     requestURL(r),
+		Description: "Posts from the feeds " + user.Name + " follows",
+		Authors:     []jsonFeedAuthor{{Name: user.Name}},
+		Items:       []jsonFeedItem{},
+	}
+	for _, post := range posts {
+		doc.Items = append(doc.Items, jsonFeedItem{
+			ID:            post.ID.String(),
+			URL:           post.Url,
+			Title:         post.Title,
+			ContentHTML:   post.Description,
+			Summary:       post.Summary,
+			DatePublished: post.PublishedAt,
+		})
+	}
+
+	data, err := marshalJSON(r, doc)
+	if err != nil {
+		respondWithError(w, r, 500, fmt.Sprintf("Couldn't encode feed: %v", err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/feed+json; charset=utf-8")
+	writeBody(w, r, 200, data)
+}
+
+// handler_get_user_atom_feed is handler_get_user_json_feed as Atom
+func (apiCfg *apiConfig) handler_get_user_atom_feed(w http.ResponseWriter, r *http.Request) {
+	user, posts, ok := apiCfg.userTimeline(w, r)
+	if !ok {
+		return
+	}
+
+	// an empty timeline was last updated when the user was created
+	updated := user.CreatedAt
+	if len(posts) > 0 {
+		updated = posts[0].PublishedAt
+	}
+	doc := atomFeedDoc{
+		Title:   userFeedTitle(user),
+		ID:      "urn:uuid:" + user.ID.String(),
+		Updated: updated.UTC().Format(time.RFC3339),
+		Link:    []atomLinkDoc{{Rel: "self", Href: requestURL(r)}},
+		Author:  atomAuthorDoc{Name: user.Name},
+		Entries: []atomEntryDoc{},
+	}
+	for _, post := range posts {
+		entry := atomEntryDoc{
+			Title:     post.Title,
+			ID:        "urn:uuid:" + post.ID.String(),
+			Published: post.PublishedAt.UTC().Format(time.RFC3339),
+			Updated:   post.PublishedAt.UTC().Format(time.RFC3339),
+			Summary:   post.Summary,
+			Content:   atomContentDoc{Type: "html", Text: post.Description},
+		}
+		if post.Url != "" {
+			entry.Link = &atomLinkDoc{Rel: "alternate", Href: post.Url}
+		}
+		doc.Entries = append(doc.Entries, entry)
+	}
+
+	data, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		respondWithError(w, r, 500, fmt.Sprintf("Couldn't encode feed: %v", err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	writeBody(w, r, 200, append([]byte(xml.Header), data...))
+}
+
+// userTimeline loads the user in the url and the newest posts from the feeds
+// they follow. The token query parameter must be the user's feed token, the
+// user id alone isn't secret enough to publish a timeline under.
+func (apiCfg *apiConfig) userTimeline(w http.ResponseWriter, r *http.Request) (database.User, []database.Post, bool) {
+	userID, err := uuid.Parse(chi.URLParam(r, "userID"))
+	if err != nil {
+		respondWithError(w, r, 400, fmt.Sprintf("Couldn't parse user id: %v", err))
+		return database.User{}, nil, false
+	}
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		respondWithError(w, r, 401, "token is required")
+		return database.User{}, nil, false
+	}
+
+	// an unknown token and another user's token look the same, so neither
+	// tells whether the user exists
+	user, err := apiCfg.DB.GetUserByFeedToken(r.Context(), token)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && user.ID != userID) {
+		respondWithError(w, r, 404, "Couldn't find timeline")
+		return database.User{}, nil, false
+	}
+	if err != nil {
+		respondWithError(w, r, 500, fmt.Sprintf("Couldn't get user: %v", err))
+		return database.User{}, nil, false
+	}
+
+	posts, err := apiCfg.DB.GetPostsForUser(r.Context(), database.GetPostsForUserParams{
+		UserID: user.ID,
+		Desc:   true,
+		Limit:  userFeedSize,
+	})
+	if err != nil {
+		respondWithError(w, r, 500, fmt.Sprintf("Couldn't get posts: %v", err))
+		return database.User{}, nil, false
+	}
+	return user, posts, true
+}
+
+func userFeedTitle(user database.User) string {
+	return user.Name + "'s rssagg timeline"
+}
+
+// requestURL is the absolute url the request was made to, for a feed's link
+// to itself
+func requestURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + r.URL.RequestURI()
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestUserTimelineNeedsFeedToken(t *testing.T) {
+	router := newTestV1Router()
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("POST", "/users", strings.NewReader(`{"name":"ann"}`)))
+	if rec.Code != 201 {
+		t.Fatalf("POST /users = %d: %s", rec.Code, rec.Body)
+	}
+	user := User{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &user); err != nil {
+		t.Fatalf("decode user: %v", err)
+	}
+	if len(user.FeedToken) != 64 {
+		t.Fatalf("feed_token = %q, want 256 random bits as hex", user.FeedToken)
+	}
+
+	other := uuid.New().String()
+	tests := []struct {
+		path string
+		want int
+	}{
+		{"/users/" + user.ID.String() + "/feed.json?token=" + user.FeedToken, 200},
+		{"/users/" + user.ID.String() + "/feed.atom?token=" + user.FeedToken, 200},
+		{"/users/" + user.ID.String() + "/feed.json", 401},
+		{"/users/" + user.ID.String() + "/feed.atom?token=", 401},
+		{"/users/" + user.ID.String() + "/feed.json?token=" + user.ApiKey, 404},
+		// the right token for the wrong user
+		{"/users/" + other + "/feed.atom?token=" + user.FeedToken, 404},
+		{"/users/not-a-uuid/feed.json?token=" + user.FeedToken, 400},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest("GET", tt.path, nil))
+		if rec.Code != tt.want {
+			t.Errorf("GET %s = %d, want %d", tt.path, rec.Code, tt.want)
+		}
+	}
+}
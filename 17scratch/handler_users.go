@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/anishakd4/rssagg/internal/auth"
+	"github.com/anishakd4/rssagg/internal/database"
+	"github.com/google/uuid"
+)
+
+func (apiCfg *apiConfig) handler_create_user(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Name string `json:"name"`
+	}
+	params := parameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, r, 400, fmt.Sprintf("Error parsing JSON: %v", err))
+		return
+	}
+	if params.Name == "" {
+		respondWithError(w, r, 400, "name is required")
+		return
+	}
+
+	apiKey, err := auth.NewAPIKey()
+	if err != nil {
+		respondWithError(w, r, 500, fmt.Sprintf("Couldn't generate api key: %v", err))
+		return
+	}
+	feedToken, err := auth.NewSecret()
+	if err != nil {
+		respondWithError(w, r, 500, fmt.Sprintf("Couldn't generate feed token: %v", err))
+		return
+	}
+
+	now := time.Now().UTC()
+	user, err := apiCfg.DB.CreateUser(r.Context(), database.CreateUserParams{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		Name:      params.Name,
+		ApiKey:    apiKey,
+		FeedToken: feedToken,
+	})
+	if err != nil {
+		respondWithError(w, r, 500, fmt.Sprintf("Couldn't create user: %v", err))
+		return
+	}
+
+	respondWithJSON(w, r, 201, databaseUserToUser(user))
+}
+
+func (apiCfg *apiConfig) handler_get_user(w http.ResponseWriter, r *http.Request, user database.User) {
+	respondWithJSON(w, r, 200, databaseUserToUser(user))
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/anishakd4/rssagg/internal/auth"
+	"github.com/anishakd4/rssagg/internal/database"
+	"github.com/go-chi/chi"
+	"github.com/google/uuid"
+)
+
+func (apiCfg *apiConfig) handler_create_webhook(w http.ResponseWriter, r *http.Request, user database.User) {
+	type parameters struct {
+		URL string `json:"url"`
+	}
+	params := parameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, r, 400, fmt.Sprintf("Error parsing JSON: %v", err))
+		return
+	}
+	if !isFeedURL(params.URL) {
+		respondWithError(w, r, 400, "url must be an absolute http(s) URL")
+		return
+	}
+	if !apiCfg.AllowPrivateNetworks {
+		if err := checkPublicURL(r.Context(), params.URL); err != nil {
+			respondWithError(w, r, 400, fmt.Sprintf("url must point at a public host: %v", err))
+			return
+		}
+	}
+
+	secret, err := auth.NewSecret()
+	if err != nil {
+		respondWithError(w, r, 500, fmt.Sprintf("Couldn't generate webhook secret: %v", err))
+		return
+	}
+
+	now := time.Now().UTC()
+	webhook, err := apiCfg.DB.CreateWebhook(r.Context(), database.CreateWebhookParams{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		UserID:    user.ID,
+		Url:       params.URL,
+		Secret:    secret,
+	})
+	if err != nil {
+		respondWithError(w, r, 500, fmt.Sprintf("Couldn't create webhook: %v", err))
+		return
+	}
+
+	// the only time the secret is handed out
+	resp := databaseWebhookToWebhook(webhook)
+	resp.Secret = webhook.Secret
+	respondWithJSON(w, r, 201, resp)
+}
+
+func (apiCfg *apiConfig) handler_get_webhooks(w http.ResponseWriter, r *http.Request, user database.User) {
+	params, err := parseListParams(r, "created_at", "created_at")
+	if err != nil {
+		respondWithError(w, r, 400, err.Error())
+		return
+	}
+
+	webhooks, err := apiCfg.DB.GetWebhooksForUser(r.Context(), database.GetWebhooksForUserParams{
+		UserID: user.ID,
+		After:  params.after,
+		Desc:   params.desc,
+		Limit:  params.fetchLimit(),
+	})
+	if err != nil {
+		respondWithError(w, r, 500, fmt.Sprintf("Couldn't get webhooks: %v", err))
+		return
+	}
+
+	webhooks, next := page(params, webhooks, func(webhook database.Webhook) (time.Time, uuid.UUID) {
+		return webhook.CreatedAt, webhook.ID
+	})
+	respondWithList(w, r, 200, databaseWebhooksToWebhooks(webhooks), next)
+}
+
+func (apiCfg *apiConfig) handler_delete_webhook(w http.ResponseWriter, r *http.Request, user database.User) {
+	webhookID, err := uuid.Parse(chi.URLParam(r, "webhookID"))
+	if err != nil {
+		respondWithError(w, r, 400, fmt.Sprintf("Couldn't parse webhook id: %v", err))
+		return
+	}
+
+	err = apiCfg.DB.DeleteWebhook(r.Context(), database.DeleteWebhookParams{
+		ID:     webhookID,
+		UserID: user.ID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, r, 404, "Couldn't find webhook")
+		return
+	}
+	if err != nil {
+		respondWithError(w, r, 500, fmt.Sprintf("Couldn't delete webhook: %v", err))
+		return
+	}
+
+	respondWithJSON(w, r, 200, struct{}{})
+}
+
+// handler_get_dead_letters lists the deliveries to a webhook that were given
+// up on, with the last error each one saw
+func (apiCfg *apiConfig) handler_get_dead_letters(w http.ResponseWriter, r *http.Request, user database.User) {
+	webhook, ok := apiCfg.webhookForUser(w, r, user)
+	if !ok {
+		return
+	}
+
+	params, err := parseListParams(r, "dead_at", "-dead_at")
+	if err != nil {
+		respondWithError(w, r, 400, err.Error())
+		return
+	}
+
+	deliveries, err := apiCfg.DB.GetDeadWebhookDeliveries(r.Context(), database.GetDeadWebhookDeliveriesParams{
+		WebhookID: webhook.ID,
+		After:     params.after,
+		Desc:      params.desc,
+		Limit:     params.fetchLimit(),
+	})
+	if err != nil {
+		respondWithError(w, r, 500, fmt.Sprintf("Couldn't get dead letters: %v", err))
+		return
+	}
+
+	deliveries, next := page(params, deliveries, func(delivery database.WebhookDelivery) (time.Time, uuid.UUID) {
+		return delivery.DeadAt.Time, delivery.ID
+	})
+	respondWithList(w, r, 200, databaseWebhookDeliveriesToWebhookDeliveries(deliveries), next)
+}
+
+// handler_retry_dead_letter queues a dead delivery again with a fresh set of
+// attempts, e.g. once the receiving end has been fixed
+func (apiCfg *apiConfig) handler_retry_dead_letter(w http.ResponseWriter, r *http.Request, user database.User) {
+	webhook, ok := apiCfg.webhookForUser(w, r, user)
+	if !ok {
+		return
+	}
+	deliveryID, err := uuid.Parse(chi.URLParam(r, "deliveryID"))
+	if err != nil {
+		respondWithError(w, r, 400, fmt.Sprintf("Couldn't parse delivery id: %v", err))
+		return
+	}
+
+	delivery, err := apiCfg.DB.RetryWebhookDelivery(r.Context(), database.RetryWebhookDeliveryParams{
+		ID:        deliveryID,
+		WebhookID: webhook.ID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, r, 404, "Couldn't find dead letter")
+		return
+	}
+	if err != nil {
+		respondWithError(w, r, 500, fmt.Sprintf("Couldn't retry delivery: %v", err))
+		return
+	}
+
+	respondWithJSON(w, r, 200, databaseWebhookDeliveryToWebhookDelivery(delivery))
+}
+
+// webhookForUser loads the webhook in the url, responding with 404 if it
+// belongs to someone else
+func (apiCfg *apiConfig) webhookForUser(w http.ResponseWriter, r *http.Request, user database.User) (database.Webhook, bool) {
+	webhookID, err := uuid.Parse(chi.URLParam(r, "webhookID"))
+	if err != nil {
+		respondWithError(w, r, 400, fmt.Sprintf("Couldn't parse webhook id: %v", err))
+		return database.Webhook{}, false
+	}
+
+	webhook, err := apiCfg.DB.GetWebhookByID(r.Context(), webhookID)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && webhook.UserID != user.ID) {
+		respondWithError(w, r, 404, "Couldn't find webhook")
+		return database.Webhook{}, false
+	}
+	if err != nil {
+		respondWithError(w, r, 500, fmt.Sprintf("Couldn't get webhook: %v", err))
+		return database.Webhook{}, false
+	}
+	return webhook, true
+}
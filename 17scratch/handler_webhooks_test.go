@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/anishakd4/rssagg/internal/database"
+	"github.com/go-chi/chi"
+	"github.com/google/uuid"
+)
+
+// getAllPages follows next_cursor from path until the last page and returns
+// the ids of every item, in order, and how many pages there were
+func getAllPages(t *testing.T, h http.Handler, path string) ([]uuid.UUID, int) {
+	t.Helper()
+	ids := []uuid.UUID{}
+	pages := 0
+	cursor := ""
+	for {
+		target := path + "?limit=2"
+		if cursor != "" {
+			target += "&cursor=" + url.QueryEscape(cursor)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", target, nil))
+		if rec.Code != 200 {
+			t.Fatalf("GET %s = %d: %s", target, rec.Code, rec.Body)
+		}
+		resp := struct {
+			Data []struct {
+				ID uuid.UUID `json:"id"`
+			} `json:"data"`
+			NextCursor *string `json:"next_cursor"`
+		}{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("GET %s isn't a list: %v: %s", target, err, rec.Body)
+		}
+		pages++
+		for _, item := range resp.Data {
+			ids = append(ids, item.ID)
+		}
+		if resp.NextCursor == nil {
+			return ids, pages
+		}
+		cursor = *resp.NextCursor
+	}
+}
+
+func TestWebhookListsArePaginated(t *testing.T) {
+	ctx := context.Background()
+	store := database.NewMemoryStore()
+	user := createTestUser(t, store)
+	feed := createTestFeed(t, store, user, "https://example.com/feed.xml")
+	apiCfg := &apiConfig{DB: store}
+
+	start := time.Now().UTC().Add(-time.Hour)
+	webhooks := []uuid.UUID{}
+	for i := 0; i < 3; i++ {
+		webhook, err := store.CreateWebhook(ctx, database.CreateWebhookParams{
+			ID:        uuid.New(),
+			CreatedAt: start.Add(time.Duration(i) * time.Minute),
+			UpdatedAt: start,
+			UserID:    user.ID,
+			Url:       "https://example.com/hook",
+			Secret:    "secret",
+		})
+		if err != nil {
+			t.Fatalf("CreateWebhook: %v", err)
+		}
+		webhooks = append(webhooks, webhook.ID)
+	}
+
+	// the webhook's dead letters, given up on a minute apart
+	dead := []uuid.UUID{}
+	for i := 0; i < 3; i++ {
+		post, err := store.CreatePost(ctx, database.CreatePostParams{
+			ID:          uuid.New(),
+			CreatedAt:   start,
+			UpdatedAt:   start,
+			Title:       "Post",
+			Url:         "https://example.com/post/" + uuid.NewString(),
+			PublishedAt: start,
+			Guid:        uuid.NewString(),
+			FeedID:      feed.ID,
+		})
+		if err != nil {
+			t.Fatalf("CreatePost: %v", err)
+		}
+		delivery, err := store.CreateWebhookDelivery(ctx, database.CreateWebhookDeliveryParams{
+			ID:            uuid.New(),
+			CreatedAt:     start,
+			UpdatedAt:     start,
+			WebhookID:     webhooks[0],
+			PostID:        post.ID,
+			NextAttemptAt: start,
+		})
+		if err != nil {
+			t.Fatalf("CreateWebhookDelivery: %v", err)
+		}
+		_, err = store.MarkWebhookDeliveryFailed(ctx, database.MarkWebhookDeliveryFailedParams{
+			ID:            delivery.ID,
+			LastError:     "500 Internal Server Error",
+			NextAttemptAt: start,
+			DeadAt:        sql.NullTime{Time: start.Add(time.Duration(i) * time.Minute), Valid: true},
+		})
+		if err != nil {
+			t.Fatalf("MarkWebhookDeliveryFailed: %v", err)
+		}
+		dead = append(dead, delivery.ID)
+	}
+
+	router := chi.NewRouter()
+	router.Get("/webhooks", func(w http.ResponseWriter, r *http.Request) {
+		apiCfg.handler_get_webhooks(w, r, user)
+	})
+	router.Get("/webhooks/{webhookID}/dead_letters", func(w http.ResponseWriter, r *http.Request) {
+		apiCfg.handler_get_dead_letters(w, r, user)
+	})
+
+	ids, pages := getAllPages(t, router, "/webhooks")
+	if pages != 2 {
+		t.Errorf("webhooks came in %d pages, want 2", pages)
+	}
+	wantIDs(t, "webhooks", ids, webhooks)
+
+	// most recently given up on first
+	ids, pages = getAllPages(t, router, "/webhooks/"+webhooks[0].String()+"/dead_letters")
+	if pages != 2 {
+		t.Errorf("dead letters came in %d pages, want 2", pages)
+	}
+	wantIDs(t, "dead letters", ids, []uuid.UUID{dead[2], dead[1], dead[0]})
+}
+
+func wantIDs(t *testing.T, what string, got, want []uuid.UUID) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d %s, want %d", len(got), what, len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("%s[%d] = %s, want %s", what, i, got[i], want[i])
+		}
+	}
+}
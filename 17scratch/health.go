@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	healthOK   = "ok"
+	healthFail = "fail"
+)
+
+type healthStatus struct {
+	Status string `json:"status"`
+}
+
+type checkResult struct {
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+type readinessResponse struct {
+	Status string                 `json:"status"`
+	Checks map[string]checkResult `json:"checks"`
+}
+
+type readinessCheck struct {
+	name    string
+	timeout time.Duration
+	check   func(ctx context.Context) error
+}
+
+// readinessChecks are the dependencies an instance needs to serve traffic.
+// /readyz runs them all, in parallel, on every request.
+type readinessChecks struct {
+	checks []readinessCheck
+}
+
+func newReadinessChecks() *readinessChecks {
+	return &readinessChecks{}
+}
+
+// register adds a check. check should return promptly once its ctx is done,
+// it's considered failed when it takes longer than timeout.
+func (rc *readinessChecks) register(name string, timeout time.Duration, check func(ctx context.Context) error) {
+	rc.checks = append(rc.checks, readinessCheck{
+		name:    name,
+		timeout: timeout,
+		check:   check,
+	})
+}
+
+func (rc *readinessChecks) run(ctx context.Context) readinessResponse {
+	resp := readinessResponse{
+		Status: healthOK,
+		Checks: make(map[string]checkResult, len(rc.checks)),
+	}
+	mu := &sync.Mutex{}
+	wg := &sync.WaitGroup{}
+	for _, c := range rc.checks {
+		wg.Add(1)
+		go func(c readinessCheck) {
+			defer wg.Done()
+			result := runCheck(ctx, c)
+
+			mu.Lock()
+			defer mu.Unlock()
+			resp.Checks[c.name] = result
+			if result.Status != healthOK {
+				resp.Status = healthFail
+			}
+		}(c)
+	}
+	wg.Wait()
+	return resp
+}
+
+func runCheck(ctx context.Context, c readinessCheck) checkResult {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	errc := make(chan error, 1)
+	go func() {
+		errc <- c.check(ctx)
+	}()
+
+	// don't trust the check to honour ctx, a hung dependency is exactly what
+	// we're looking for
+	var err error
+	select {
+	case err = <-errc:
+	case <-ctx.Done():
+		err = fmt.Errorf("timed out after %s", c.timeout)
+	}
+
+	result := checkResult{
+		Status:    healthOK,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status = healthFail
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// handler_readyz responds 503 when any check fails so load balancers stop
+// sending this instance traffic
+func (rc *readinessChecks) handler_readyz(w http.ResponseWriter, r *http.Request) {
+	resp := rc.run(r.Context())
+	code := 200
+	if resp.Status != healthOK {
+		code = 503
+	}
+	respondWithJSON(w, r, code, resp)
+}
@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+var ErrNoAuthHeaderIncluded = errors.New("no authentication info found")
+
+// GetAPIKey extracts an API key from the headers of an HTTP request
+// Example:
+// Authorization: ApiKey {insert apikey here}
+func GetAPIKey(headers http.Header) (string, error) {
+	val := headers.Get("Authorization")
+	if val == "" {
+		return "", ErrNoAuthHeaderIncluded
+	}
+
+	vals := strings.Split(val, " ")
+	if len(vals) != 2 {
+		return "", errors.New("malformed auth header")
+	}
+	if vals[0] != "ApiKey" {
+		return "", errors.New("malformed first part of auth header")
+	}
+	if vals[1] == "" {
+		return "", errors.New("malformed api key in auth header")
+	}
+	return vals[1], nil
+}
+
+// NewAPIKey returns a random 256 bit key encoded as hex
+func NewAPIKey() (string, error) {
+	return NewSecret()
+}
+
+// NewSecret returns a random 256 bit secret encoded as hex, e.g. for signing
+// webhook payloads
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
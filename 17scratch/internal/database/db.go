@@ -0,0 +1,117 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// DBTX is satisfied by both *sql.DB and *sql.Tx so queries can run inside
+// or outside of a transaction.
+type DBTX interface {
+	ExecContext(context.Context, string, ...interface{}) (sql.Result, error)
+	QueryContext(context.Context, string, ...interface{}) (*sql.Rows, error)
+	QueryRowContext(context.Context, string, ...interface{}) *sql.Row
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+type Queries struct {
+	db DBTX
+}
+
+func New(db DBTX) *Queries {
+	return &Queries{db: db}
+}
+
+func (q *Queries) WithTx(tx *sql.Tx) *Queries {
+	return &Queries{db: tx}
+}
+
+// Cursor is a position in a list sorted by a time and then id. Pages after
+// the first only return rows strictly past it, which stays correct while rows
+// are being inserted, unlike an offset.
+type Cursor struct {
+	Time  time.Time
+	ID    uuid.UUID
+	Valid bool
+}
+
+func (c Cursor) nullTime() sql.NullTime {
+	return sql.NullTime{Time: c.Time, Valid: c.Valid}
+}
+
+// pageQuery fills in the keyset comparison and the sort direction of a list
+// query written with three %s verbs
+func pageQuery(query string, desc bool) string {
+	if desc {
+		return fmt.Sprintf(query, "<", "DESC", "DESC")
+	}
+	return fmt.Sprintf(query, ">", "ASC", "ASC")
+}
+
+// containsPattern turns a search term into a LIKE pattern matching it
+// anywhere, escaping LIKE's own wildcards. An empty term stays empty so
+// queries can skip the filter.
+func containsPattern(term string) string {
+	if term == "" {
+		return ""
+	}
+	escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(term)
+	return "%" + escaped + "%"
+}
+
+// Open opens the SQLite database described by dsn, e.g. "file:rssagg.db".
+// Foreign keys are always enabled and times are stored in a sortable format.
+func Open(dsn string) (*sql.DB, error) {
+	sep := "?"
+	if strings.Contains(dsn, "?") {
+		sep = "&"
+	}
+	dsn += sep + "_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_time_format=sqlite"
+
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	// sqlite only allows a single writer, so don't let the pool fight over it
+	db.SetMaxOpenConns(1)
+	return db, nil
+}
+
+// IsUniqueViolation reports whether err was caused by a UNIQUE constraint,
+// e.g. following the same feed twice.
+func IsUniqueViolation(err error) bool {
+	if errors.Is(err, ErrUniqueViolation) {
+		return true
+	}
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE ||
+			sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY
+	}
+	return false
+}
+
+// IsForeignKeyViolation reports whether err was caused by a reference to a
+// row that doesn't exist, e.g. following an unknown feed.
+func IsForeignKeyViolation(err error) bool {
+	if errors.Is(err, ErrForeignKeyViolation) {
+		return true
+	}
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY
+	}
+	return false
+}
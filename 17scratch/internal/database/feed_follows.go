@@ -0,0 +1,108 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const feedFollowColumns = `id, created_at, updated_at, user_id, feed_id`
+
+func scanFeedFollow(row rowScanner) (FeedFollow, error) {
+	var i FeedFollow
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.FeedID,
+	)
+	return i, err
+}
+
+const createFeedFollow = `
+INSERT INTO feed_follows (id, created_at, updated_at, user_id, feed_id)
+VALUES (?, ?, ?, ?, ?)
+RETURNING ` + feedFollowColumns
+
+type CreateFeedFollowParams struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	UserID    uuid.UUID
+	FeedID    uuid.UUID
+}
+
+func (q *Queries) CreateFeedFollow(ctx context.Context, arg CreateFeedFollowParams) (FeedFollow, error) {
+	return scanFeedFollow(q.db.QueryRowContext(ctx, createFeedFollow,
+		arg.ID,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+		arg.UserID,
+		arg.FeedID,
+	))
+}
+
+const getFeedFollows = `
+SELECT ` + feedFollowColumns + ` FROM feed_follows
+WHERE user_id = ?1
+	AND (?2 IS NULL OR feed_id = ?2)
+	AND (?3 IS NULL OR created_at >= ?3)
+	AND (?4 IS NULL OR (created_at, id) %s (?4, ?5))
+ORDER BY created_at %s, id %s
+LIMIT ?6
+`
+
+type GetFeedFollowsParams struct {
+	UserID uuid.UUID
+	FeedID uuid.NullUUID
+	Since  sql.NullTime
+	After  Cursor
+	// Desc lists the newest follows first
+	Desc  bool
+	Limit int32
+}
+
+func (q *Queries) GetFeedFollows(ctx context.Context, arg GetFeedFollowsParams) ([]FeedFollow, error) {
+	rows, err := q.db.QueryContext(ctx, pageQuery(getFeedFollows, arg.Desc),
+		arg.UserID,
+		arg.FeedID,
+		arg.Since,
+		arg.After.nullTime(),
+		arg.After.ID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []FeedFollow{}
+	for rows.Next() {
+		i, err := scanFeedFollow(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	return items, rows.Err()
+}
+
+const deleteFeedFollow = `
+DELETE FROM feed_follows WHERE id = ? AND user_id = ?
+RETURNING id
+`
+
+type DeleteFeedFollowParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+// DeleteFeedFollow returns sql.ErrNoRows if the follow doesn't exist or
+// belongs to someone else.
+func (q *Queries) DeleteFeedFollow(ctx context.Context, arg DeleteFeedFollowParams) error {
+	var id uuid.UUID
+	return q.db.QueryRowContext(ctx, deleteFeedFollow, arg.ID, arg.UserID).Scan(&id)
+}
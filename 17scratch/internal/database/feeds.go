@@ -0,0 +1,204 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const feedColumns = `id, created_at, updated_at, name, url, user_id, last_fetched_at,
+	etag, last_modified, failure_count, last_error, next_fetch_at, dead_at`
+
+func scanFeed(row rowScanner) (Feed, error) {
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.UserID,
+		&i.LastFetchedAt,
+		&i.Etag,
+		&i.LastModified,
+		&i.FailureCount,
+		&i.LastError,
+		&i.NextFetchAt,
+		&i.DeadAt,
+	)
+	return i, err
+}
+
+func (q *Queries) queryFeeds(ctx context.Context, query string, args ...interface{}) ([]Feed, error) {
+	rows, err := q.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []Feed{}
+	for rows.Next() {
+		i, err := scanFeed(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	return items, rows.Err()
+}
+
+const createFeed = `
+INSERT INTO feeds (id, created_at, updated_at, name, url, user_id)
+VALUES (?, ?, ?, ?, ?, ?)
+RETURNING ` + feedColumns
+
+type CreateFeedParams struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Name      string
+	Url       string
+	UserID    uuid.UUID
+}
+
+func (q *Queries) CreateFeed(ctx context.Context, arg CreateFeedParams) (Feed, error) {
+	return scanFeed(q.db.QueryRowContext(ctx, createFeed,
+		arg.ID,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+		arg.Name,
+		arg.Url,
+		arg.UserID,
+	))
+}
+
+const getFeeds = `
+SELECT ` + feedColumns + ` FROM feeds
+WHERE (?1 = '' OR name LIKE ?1 ESCAPE '\')
+	AND (?2 IS NULL OR created_at >= ?2)
+	AND (?3 IS NULL OR (created_at, id) %s (?3, ?4))
+ORDER BY created_at %s, id %s
+LIMIT ?5
+`
+
+type GetFeedsParams struct {
+	// Query only returns feeds whose name contains it when set
+	Query string
+	Since sql.NullTime
+	After Cursor
+	// Desc lists the newest feeds first
+	Desc  bool
+	Limit int32
+}
+
+// GetFeeds pages through every feed in the order they were added
+func (q *Queries) GetFeeds(ctx context.Context, arg GetFeedsParams) ([]Feed, error) {
+	return q.queryFeeds(ctx, pageQuery(getFeeds, arg.Desc),
+		containsPattern(arg.Query),
+		arg.Since,
+		arg.After.nullTime(),
+		arg.After.ID,
+		arg.Limit,
+	)
+}
+
+const getNextFeedsToFetch = `
+SELECT ` + feedColumns + ` FROM feeds
+WHERE dead_at IS NULL AND (next_fetch_at IS NULL OR next_fetch_at <= ?)
+ORDER BY last_fetched_at ASC NULLS FIRST
+LIMIT ?
+`
+
+type GetNextFeedsToFetchParams struct {
+	Now   time.Time
+	Limit int32
+}
+
+// GetNextFeedsToFetch skips dead feeds and feeds that are backing off
+func (q *Queries) GetNextFeedsToFetch(ctx context.Context, arg GetNextFeedsToFetchParams) ([]Feed, error) {
+	return q.queryFeeds(ctx, getNextFeedsToFetch, arg.Now, arg.Limit)
+}
+
+const markFeedFetched = `
+UPDATE feeds SET last_fetched_at = ?, updated_at = ?
+WHERE id = ?
+RETURNING ` + feedColumns
+
+func (q *Queries) MarkFeedFetched(ctx context.Context, id uuid.UUID) (Feed, error) {
+	now := time.Now().UTC()
+	return scanFeed(q.db.QueryRowContext(ctx, markFeedFetched, now, now, id))
+}
+
+const getFeedByURL = `
+SELECT ` + feedColumns + ` FROM feeds WHERE url = ?
+`
+
+func (q *Queries) GetFeedByURL(ctx context.Context, url string) (Feed, error) {
+	return scanFeed(q.db.QueryRowContext(ctx, getFeedByURL, url))
+}
+
+const getFeedsForUser = `
+SELECT ` + feedColumns + ` FROM feeds
+WHERE id IN (SELECT feed_id FROM feed_follows WHERE user_id = ?)
+ORDER BY created_at
+`
+
+// GetFeedsForUser returns the feeds the user follows
+func (q *Queries) GetFeedsForUser(ctx context.Context, userID uuid.UUID) ([]Feed, error) {
+	return q.queryFeeds(ctx, getFeedsForUser, userID)
+}
+
+const getFeedByID = `
+SELECT ` + feedColumns + ` FROM feeds WHERE id = ?
+`
+
+func (q *Queries) GetFeedByID(ctx context.Context, id uuid.UUID) (Feed, error) {
+	return scanFeed(q.db.QueryRowContext(ctx, getFeedByID, id))
+}
+
+const markFeedFetchSucceeded = `
+UPDATE feeds SET etag = ?, last_modified = ?, failure_count = 0, last_error = '',
+	next_fetch_at = NULL, updated_at = ?
+WHERE id = ?
+RETURNING ` + feedColumns
+
+type MarkFeedFetchSucceededParams struct {
+	ID           uuid.UUID
+	Etag         string
+	LastModified string
+}
+
+// MarkFeedFetchSucceeded stores the new validators and clears any backoff
+func (q *Queries) MarkFeedFetchSucceeded(ctx context.Context, arg MarkFeedFetchSucceededParams) (Feed, error) {
+	return scanFeed(q.db.QueryRowContext(ctx, markFeedFetchSucceeded,
+		arg.Etag,
+		arg.LastModified,
+		time.Now().UTC(),
+		arg.ID,
+	))
+}
+
+const markFeedFetchFailed = `
+UPDATE feeds SET failure_count = failure_count + 1, last_error = ?, next_fetch_at = ?,
+	dead_at = ?, updated_at = ?
+WHERE id = ?
+RETURNING ` + feedColumns
+
+type MarkFeedFetchFailedParams struct {
+	ID          uuid.UUID
+	LastError   string
+	NextFetchAt sql.NullTime
+	DeadAt      sql.NullTime
+}
+
+func (q *Queries) MarkFeedFetchFailed(ctx context.Context, arg MarkFeedFetchFailedParams) (Feed, error) {
+	return scanFeed(q.db.QueryRowContext(ctx, markFeedFetchFailed,
+		arg.LastError,
+		arg.NextFetchAt,
+		arg.DeadAt,
+		time.Now().UTC(),
+		arg.ID,
+	))
+}
@@ -0,0 +1,875 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MemoryStore keeps everything in maps guarded by a RWMutex. It enforces the
+// same constraints as the sql schema so handlers behave the same on both
+// backends, and is meant for tests and local hacking.
+type MemoryStore struct {
+	users       map[uuid.UUID]User
+	feeds       map[uuid.UUID]Feed
+	feedFollows map[uuid.UUID]FeedFollow
+	posts       map[uuid.UUID]Post
+	postStates  map[postStateKey]PostState
+	webhooks    map[uuid.UUID]Webhook
+	deliveries  map[uuid.UUID]WebhookDelivery
+	settings    map[string]string
+	mu          *sync.RWMutex
+}
+
+type postStateKey struct {
+	userID uuid.UUID
+	postID uuid.UUID
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		users:       make(map[uuid.UUID]User),
+		feeds:       make(map[uuid.UUID]Feed),
+		feedFollows: make(map[uuid.UUID]FeedFollow),
+		posts:       make(map[uuid.UUID]Post),
+		postStates:  make(map[postStateKey]PostState),
+		webhooks:    make(map[uuid.UUID]Webhook),
+		deliveries:  make(map[uuid.UUID]WebhookDelivery),
+		settings:    make(map[string]string),
+		mu:          &sync.RWMutex{},
+	}
+}
+
+func (m *MemoryStore) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (m *MemoryStore) Close() error {
+	return nil
+}
+
+func (m *MemoryStore) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[arg.ID]; ok {
+		return User{}, ErrUniqueViolation
+	}
+	for _, user := range m.users {
+		if user.ApiKey == arg.ApiKey || user.FeedToken == arg.FeedToken {
+			return User{}, ErrUniqueViolation
+		}
+	}
+
+	user := User{
+		ID:        arg.ID,
+		CreatedAt: arg.CreatedAt,
+		UpdatedAt: arg.UpdatedAt,
+		Name:      arg.Name,
+		ApiKey:    arg.ApiKey,
+		FeedToken: arg.FeedToken,
+	}
+	m.users[user.ID] = user
+	return user, nil
+}
+
+func (m *MemoryStore) GetUserByAPIKey(ctx context.Context, apiKey string) (User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, user := range m.users {
+		if user.ApiKey == apiKey {
+			return user, nil
+		}
+	}
+	return User{}, sql.ErrNoRows
+}
+
+func (m *MemoryStore) GetUserByID(ctx context.Context, id uuid.UUID) (User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	user, ok := m.users[id]
+	if !ok {
+		return User{}, sql.ErrNoRows
+	}
+	return user, nil
+}
+
+func (m *MemoryStore) GetUserByFeedToken(ctx context.Context, feedToken string) (User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, user := range m.users {
+		if user.FeedToken == feedToken {
+			return user, nil
+		}
+	}
+	return User{}, sql.ErrNoRows
+}
+
+func (m *MemoryStore) CreateFeed(ctx context.Context, arg CreateFeedParams) (Feed, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.feeds[arg.ID]; ok {
+		return Feed{}, ErrUniqueViolation
+	}
+	for _, feed := range m.feeds {
+		if feed.Url == arg.Url {
+			return Feed{}, ErrUniqueViolation
+		}
+	}
+	if _, ok := m.users[arg.UserID]; !ok {
+		return Feed{}, ErrForeignKeyViolation
+	}
+
+	feed := Feed{
+		ID:        arg.ID,
+		CreatedAt: arg.CreatedAt,
+		UpdatedAt: arg.UpdatedAt,
+		Name:      arg.Name,
+		Url:       arg.Url,
+		UserID:    arg.UserID,
+	}
+	m.feeds[feed.ID] = feed
+	return feed, nil
+}
+
+func (m *MemoryStore) GetFeeds(ctx context.Context, arg GetFeedsParams) ([]Feed, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	feeds := []Feed{}
+	for _, feed := range m.feeds {
+		if !containsFold(feed.Name, arg.Query) {
+			continue
+		}
+		if arg.Since.Valid && feed.CreatedAt.Before(arg.Since.Time) {
+			continue
+		}
+		if !pastCursor(feed.CreatedAt, feed.ID, arg.After, arg.Desc) {
+			continue
+		}
+		feeds = append(feeds, feed)
+	}
+	sort.Slice(feeds, func(i, j int) bool {
+		return keyLess(feeds[i].CreatedAt, feeds[i].ID, feeds[j].CreatedAt, feeds[j].ID, arg.Desc)
+	})
+	if len(feeds) > int(arg.Limit) {
+		feeds = feeds[:arg.Limit]
+	}
+	return feeds, nil
+}
+
+func (m *MemoryStore) GetFeedByURL(ctx context.Context, url string) (Feed, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, feed := range m.feeds {
+		if feed.Url == url {
+			return feed, nil
+		}
+	}
+	return Feed{}, sql.ErrNoRows
+}
+
+func (m *MemoryStore) GetFeedsForUser(ctx context.Context, userID uuid.UUID) ([]Feed, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	feeds := []Feed{}
+	for feedID := range m.followedFeedIDs(userID) {
+		feeds = append(feeds, m.feeds[feedID])
+	}
+	sort.Slice(feeds, func(i, j int) bool {
+		return feeds[i].CreatedAt.Before(feeds[j].CreatedAt)
+	})
+	return feeds, nil
+}
+
+func (m *MemoryStore) GetFeedByID(ctx context.Context, id uuid.UUID) (Feed, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	feed, ok := m.feeds[id]
+	if !ok {
+		return Feed{}, sql.ErrNoRows
+	}
+	return feed, nil
+}
+
+func (m *MemoryStore) GetNextFeedsToFetch(ctx context.Context, arg GetNextFeedsToFetchParams) ([]Feed, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	feeds := []Feed{}
+	for _, feed := range m.feeds {
+		if feed.DeadAt.Valid {
+			continue
+		}
+		if feed.NextFetchAt.Valid && feed.NextFetchAt.Time.After(arg.Now) {
+			continue
+		}
+		feeds = append(feeds, feed)
+	}
+	// never fetched feeds go first
+	sort.Slice(feeds, func(i, j int) bool {
+		a, b := feeds[i].LastFetchedAt, feeds[j].LastFetchedAt
+		if a.Valid != b.Valid {
+			return !a.Valid
+		}
+		return a.Time.Before(b.Time)
+	})
+	if len(feeds) > int(arg.Limit) {
+		feeds = feeds[:arg.Limit]
+	}
+	return feeds, nil
+}
+
+func (m *MemoryStore) MarkFeedFetched(ctx context.Context, id uuid.UUID) (Feed, error) {
+	return m.updateFeed(id, func(feed *Feed) {
+		feed.LastFetchedAt = sql.NullTime{Time: feed.UpdatedAt, Valid: true}
+	})
+}
+
+func (m *MemoryStore) MarkFeedFetchSucceeded(ctx context.Context, arg MarkFeedFetchSucceededParams) (Feed, error) {
+	return m.updateFeed(arg.ID, func(feed *Feed) {
+		feed.Etag = arg.Etag
+		feed.LastModified = arg.LastModified
+		feed.FailureCount = 0
+		feed.LastError = ""
+		feed.NextFetchAt = sql.NullTime{}
+	})
+}
+
+func (m *MemoryStore) MarkFeedFetchFailed(ctx context.Context, arg MarkFeedFetchFailedParams) (Feed, error) {
+	return m.updateFeed(arg.ID, func(feed *Feed) {
+		feed.FailureCount++
+		feed.LastError = arg.LastError
+		feed.NextFetchAt = arg.NextFetchAt
+		feed.DeadAt = arg.DeadAt
+	})
+}
+
+// updateFeed applies fn to a copy of the feed with UpdatedAt already bumped
+func (m *MemoryStore) updateFeed(id uuid.UUID, fn func(feed *Feed)) (Feed, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	feed, ok := m.feeds[id]
+	if !ok {
+		return Feed{}, sql.ErrNoRows
+	}
+	feed.UpdatedAt = time.Now().UTC()
+	fn(&feed)
+	m.feeds[id] = feed
+	return feed, nil
+}
+
+func (m *MemoryStore) CreateFeedFollow(ctx context.Context, arg CreateFeedFollowParams) (FeedFollow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.feedFollows[arg.ID]; ok {
+		return FeedFollow{}, ErrUniqueViolation
+	}
+	for _, feedFollow := range m.feedFollows {
+		if feedFollow.UserID == arg.UserID && feedFollow.FeedID == arg.FeedID {
+			return FeedFollow{}, ErrUniqueViolation
+		}
+	}
+	if _, ok := m.users[arg.UserID]; !ok {
+		return FeedFollow{}, ErrForeignKeyViolation
+	}
+	if _, ok := m.feeds[arg.FeedID]; !ok {
+		return FeedFollow{}, ErrForeignKeyViolation
+	}
+
+	feedFollow := FeedFollow{
+		ID:        arg.ID,
+		CreatedAt: arg.CreatedAt,
+		UpdatedAt: arg.UpdatedAt,
+		UserID:    arg.UserID,
+		FeedID:    arg.FeedID,
+	}
+	m.feedFollows[feedFollow.ID] = feedFollow
+	return feedFollow, nil
+}
+
+func (m *MemoryStore) GetFeedFollows(ctx context.Context, arg GetFeedFollowsParams) ([]FeedFollow, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	feedFollows := []FeedFollow{}
+	for _, feedFollow := range m.feedFollows {
+		if feedFollow.UserID != arg.UserID {
+			continue
+		}
+		if arg.FeedID.Valid && feedFollow.FeedID != arg.FeedID.UUID {
+			continue
+		}
+		if arg.Since.Valid && feedFollow.CreatedAt.Before(arg.Since.Time) {
+			continue
+		}
+		if !pastCursor(feedFollow.CreatedAt, feedFollow.ID, arg.After, arg.Desc) {
+			continue
+		}
+		feedFollows = append(feedFollows, feedFollow)
+	}
+	sort.Slice(feedFollows, func(i, j int) bool {
+		a, b := feedFollows[i], feedFollows[j]
+		return keyLess(a.CreatedAt, a.ID, b.CreatedAt, b.ID, arg.Desc)
+	})
+	if len(feedFollows) > int(arg.Limit) {
+		feedFollows = feedFollows[:arg.Limit]
+	}
+	return feedFollows, nil
+}
+
+func (m *MemoryStore) DeleteFeedFollow(ctx context.Context, arg DeleteFeedFollowParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	feedFollow, ok := m.feedFollows[arg.ID]
+	if !ok || feedFollow.UserID != arg.UserID {
+		return sql.ErrNoRows
+	}
+	delete(m.feedFollows, arg.ID)
+	return nil
+}
+
+func (m *MemoryStore) CreatePost(ctx context.Context, arg CreatePostParams) (Post, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.posts[arg.ID]; ok {
+		return Post{}, ErrUniqueViolation
+	}
+	for _, post := range m.posts {
+		if post.FeedID != arg.FeedID {
+			continue
+		}
+		if post.Guid == arg.Guid || (arg.Url != "" && post.Url == arg.Url) {
+			return Post{}, ErrUniqueViolation
+		}
+	}
+	if _, ok := m.feeds[arg.FeedID]; !ok {
+		return Post{}, ErrForeignKeyViolation
+	}
+
+	post := Post{
+		ID:          arg.ID,
+		CreatedAt:   arg.CreatedAt,
+		UpdatedAt:   arg.UpdatedAt,
+		Title:       arg.Title,
+		Url:         arg.Url,
+		Description: arg.Description,
+		PublishedAt: arg.PublishedAt,
+		Guid:        arg.Guid,
+		FeedID:      arg.FeedID,
+		Summary:     arg.Summary,
+	}
+	m.posts[post.ID] = post
+	return post, nil
+}
+
+func (m *MemoryStore) UpdatePostContent(ctx context.Context, arg UpdatePostContentParams) (Post, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	post, ok := m.posts[arg.ID]
+	if !ok {
+		return Post{}, sql.ErrNoRows
+	}
+	post.Description = arg.Description
+	post.Summary = arg.Summary
+	post.UpdatedAt = arg.UpdatedAt
+	m.posts[post.ID] = post
+	return post, nil
+}
+
+func (m *MemoryStore) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams) ([]Post, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	followed := m.followedFeedIDs(arg.UserID)
+	posts := []Post{}
+	for _, post := range m.posts {
+		if !followed[post.FeedID] {
+			continue
+		}
+		if arg.FeedID.Valid && post.FeedID != arg.FeedID.UUID {
+			continue
+		}
+		if arg.Since.Valid && post.PublishedAt.Before(arg.Since.Time) {
+			continue
+		}
+		if arg.Before.Valid && !post.PublishedAt.Before(arg.Before.Time) {
+			continue
+		}
+		if !containsFold(post.Title, arg.Query) {
+			continue
+		}
+		if !m.postInState(arg.UserID, post.ID, arg.State) {
+			continue
+		}
+		if !pastCursor(post.PublishedAt, post.ID, arg.After, arg.Desc) {
+			continue
+		}
+		posts = append(posts, post)
+	}
+	sort.Slice(posts, func(i, j int) bool {
+		return keyLess(posts[i].PublishedAt, posts[i].ID, posts[j].PublishedAt, posts[j].ID, arg.Desc)
+	})
+	if len(posts) > int(arg.Limit) {
+		posts = posts[:arg.Limit]
+	}
+	return posts, nil
+}
+
+func (m *MemoryStore) GetPosts(ctx context.Context, arg GetPostsParams) ([]Post, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	posts := []Post{}
+	for _, post := range m.posts {
+		if pastCursor(post.CreatedAt, post.ID, arg.After, false) {
+			posts = append(posts, post)
+		}
+	}
+	sort.Slice(posts, func(i, j int) bool {
+		return keyLess(posts[i].CreatedAt, posts[i].ID, posts[j].CreatedAt, posts[j].ID, false)
+	})
+	if len(posts) > int(arg.Limit) {
+		posts = posts[:arg.Limit]
+	}
+	return posts, nil
+}
+
+func (m *MemoryStore) GetPostByID(ctx context.Context, id uuid.UUID) (Post, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	post, ok := m.posts[id]
+	if !ok {
+		return Post{}, sql.ErrNoRows
+	}
+	return post, nil
+}
+
+func (m *MemoryStore) GetPostsForUserCreatedAfter(ctx context.Context, arg GetPostsForUserCreatedAfterParams) ([]Post, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	followed := m.followedFeedIDs(arg.UserID)
+	posts := []Post{}
+	for _, post := range m.posts {
+		if followed[post.FeedID] && pastCursor(post.CreatedAt, post.ID, arg.After, false) {
+			posts = append(posts, post)
+		}
+	}
+	sort.Slice(posts, func(i, j int) bool {
+		a, b := posts[i], posts[j]
+		return keyLess(a.CreatedAt, a.ID, b.CreatedAt, b.ID, false)
+	})
+	if len(posts) > int(arg.Limit) {
+		posts = posts[:arg.Limit]
+	}
+	return posts, nil
+}
+
+// followedFeedIDs must be called with m.mu held
+func (m *MemoryStore) followedFeedIDs(userID uuid.UUID) map[uuid.UUID]bool {
+	followed := map[uuid.UUID]bool{}
+	for _, feedFollow := range m.feedFollows {
+		if feedFollow.UserID == userID {
+			followed[feedFollow.FeedID] = true
+		}
+	}
+	return followed
+}
+
+// keyLess matches ORDER BY t, id with both columns in the same direction
+func keyLess(aTime time.Time, aID uuid.UUID, bTime time.Time, bID uuid.UUID, desc bool) bool {
+	if !aTime.Equal(bTime) {
+		return aTime.Before(bTime) != desc
+	}
+	if aID == bID {
+		return false
+	}
+	return (aID.String() < bID.String()) != desc
+}
+
+// pastCursor matches the keyset condition of pageQuery
+func pastCursor(t time.Time, id uuid.UUID, after Cursor, desc bool) bool {
+	if !after.Valid {
+		return true
+	}
+	return keyLess(after.Time, after.ID, t, id, desc)
+}
+
+// containsFold matches LIKE '%term%', which ignores ASCII case
+func containsFold(s, term string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(term))
+}
+
+// postInState matches the state filter of GetPostsForUser
+func (m *MemoryStore) postInState(userID, postID uuid.UUID, state string) bool {
+	postState := m.postStates[postStateKey{userID: userID, postID: postID}]
+	switch state {
+	case PostStateUnread:
+		return !postState.ReadAt.Valid
+	case PostStateRead:
+		return postState.ReadAt.Valid
+	case PostStateStarred:
+		return postState.StarredAt.Valid
+	}
+	return true
+}
+
+func (m *MemoryStore) SetPostRead(ctx context.Context, arg SetPostReadParams) (PostState, error) {
+	return m.updatePostState(arg.UserID, arg.PostID, func(postState *PostState) {
+		postState.ReadAt = setFlag(postState.ReadAt, arg.Read, postState.UpdatedAt)
+	})
+}
+
+func (m *MemoryStore) SetPostStarred(ctx context.Context, arg SetPostStarredParams) (PostState, error) {
+	return m.updatePostState(arg.UserID, arg.PostID, func(postState *PostState) {
+		postState.StarredAt = setFlag(postState.StarredAt, arg.Starred, postState.UpdatedAt)
+	})
+}
+
+// setFlag keeps the time a flag was first set, like the sql upserts
+func setFlag(flag sql.NullTime, set bool, now time.Time) sql.NullTime {
+	if !set {
+		return sql.NullTime{}
+	}
+	if flag.Valid {
+		return flag
+	}
+	return sql.NullTime{Time: now, Valid: true}
+}
+
+// updatePostState applies fn to the user's state of the post, creating it if
+// there isn't one yet, with UpdatedAt already bumped
+func (m *MemoryStore) updatePostState(userID, postID uuid.UUID, fn func(postState *PostState)) (PostState, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[userID]; !ok {
+		return PostState{}, ErrForeignKeyViolation
+	}
+	if _, ok := m.posts[postID]; !ok {
+		return PostState{}, ErrForeignKeyViolation
+	}
+
+	key := postStateKey{userID: userID, postID: postID}
+	now := time.Now().UTC()
+	postState, ok := m.postStates[key]
+	if !ok {
+		postState = PostState{UserID: userID, PostID: postID, CreatedAt: now}
+	}
+	postState.UpdatedAt = now
+	fn(&postState)
+	m.postStates[key] = postState
+	return postState, nil
+}
+
+func (m *MemoryStore) MarkPostsRead(ctx context.Context, arg MarkPostsReadParams) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	followed := m.followedFeedIDs(arg.UserID)
+	now := time.Now().UTC()
+	var marked int64
+	for _, post := range m.posts {
+		if !followed[post.FeedID] {
+			continue
+		}
+		if arg.FeedID.Valid && post.FeedID != arg.FeedID.UUID {
+			continue
+		}
+		if arg.Before.Valid && !post.PublishedAt.Before(arg.Before.Time) {
+			continue
+		}
+
+		key := postStateKey{userID: arg.UserID, postID: post.ID}
+		postState, ok := m.postStates[key]
+		if !ok {
+			postState = PostState{UserID: arg.UserID, PostID: post.ID, CreatedAt: now}
+		}
+		if postState.ReadAt.Valid {
+			continue
+		}
+		postState.UpdatedAt = now
+		postState.ReadAt = sql.NullTime{Time: now, Valid: true}
+		m.postStates[key] = postState
+		marked++
+	}
+	return marked, nil
+}
+
+func (m *MemoryStore) GetPostStates(ctx context.Context, arg GetPostStatesParams) ([]PostState, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	postStates := []PostState{}
+	for _, postID := range arg.PostIDs {
+		if postState, ok := m.postStates[postStateKey{userID: arg.UserID, postID: postID}]; ok {
+			postStates = append(postStates, postState)
+		}
+	}
+	return postStates, nil
+}
+
+func (m *MemoryStore) CreateWebhook(ctx context.Context, arg CreateWebhookParams) (Webhook, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.webhooks[arg.ID]; ok {
+		return Webhook{}, ErrUniqueViolation
+	}
+	if _, ok := m.users[arg.UserID]; !ok {
+		return Webhook{}, ErrForeignKeyViolation
+	}
+
+	webhook := Webhook{
+		ID:        arg.ID,
+		CreatedAt: arg.CreatedAt,
+		UpdatedAt: arg.UpdatedAt,
+		UserID:    arg.UserID,
+		Url:       arg.Url,
+		Secret:    arg.Secret,
+	}
+	m.webhooks[webhook.ID] = webhook
+	return webhook, nil
+}
+
+func (m *MemoryStore) GetWebhooksForUser(ctx context.Context, arg GetWebhooksForUserParams) ([]Webhook, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	webhooks := []Webhook{}
+	for _, webhook := range m.webhooks {
+		if webhook.UserID != arg.UserID {
+			continue
+		}
+		if !pastCursor(webhook.CreatedAt, webhook.ID, arg.After, arg.Desc) {
+			continue
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	sort.Slice(webhooks, func(i, j int) bool {
+		a, b := webhooks[i], webhooks[j]
+		return keyLess(a.CreatedAt, a.ID, b.CreatedAt, b.ID, arg.Desc)
+	})
+	if len(webhooks) > int(arg.Limit) {
+		webhooks = webhooks[:arg.Limit]
+	}
+	return webhooks, nil
+}
+
+func (m *MemoryStore) GetWebhookByID(ctx context.Context, id uuid.UUID) (Webhook, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	webhook, ok := m.webhooks[id]
+	if !ok {
+		return Webhook{}, sql.ErrNoRows
+	}
+	return webhook, nil
+}
+
+func (m *MemoryStore) GetWebhooksForFeed(ctx context.Context, feedID uuid.UUID) ([]Webhook, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	followers := map[uuid.UUID]bool{}
+	for _, feedFollow := range m.feedFollows {
+		if feedFollow.FeedID == feedID {
+			followers[feedFollow.UserID] = true
+		}
+	}
+	webhooks := []Webhook{}
+	for _, webhook := range m.webhooks {
+		if followers[webhook.UserID] {
+			webhooks = append(webhooks, webhook)
+		}
+	}
+	sortWebhooksOldestFirst(webhooks)
+	return webhooks, nil
+}
+
+func (m *MemoryStore) DeleteWebhook(ctx context.Context, arg DeleteWebhookParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	webhook, ok := m.webhooks[arg.ID]
+	if !ok || webhook.UserID != arg.UserID {
+		return sql.ErrNoRows
+	}
+	delete(m.webhooks, arg.ID)
+	for id, delivery := range m.deliveries {
+		if delivery.WebhookID == arg.ID {
+			delete(m.deliveries, id)
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) CreateWebhookDelivery(ctx context.Context, arg CreateWebhookDeliveryParams) (WebhookDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.deliveries[arg.ID]; ok {
+		return WebhookDelivery{}, ErrUniqueViolation
+	}
+	for _, delivery := range m.deliveries {
+		if delivery.WebhookID == arg.WebhookID && delivery.PostID == arg.PostID {
+			return WebhookDelivery{}, ErrUniqueViolation
+		}
+	}
+	if _, ok := m.webhooks[arg.WebhookID]; !ok {
+		return WebhookDelivery{}, ErrForeignKeyViolation
+	}
+	if _, ok := m.posts[arg.PostID]; !ok {
+		return WebhookDelivery{}, ErrForeignKeyViolation
+	}
+
+	delivery := WebhookDelivery{
+		ID:            arg.ID,
+		CreatedAt:     arg.CreatedAt,
+		UpdatedAt:     arg.UpdatedAt,
+		WebhookID:     arg.WebhookID,
+		PostID:        arg.PostID,
+		NextAttemptAt: arg.NextAttemptAt,
+	}
+	m.deliveries[delivery.ID] = delivery
+	return delivery, nil
+}
+
+func (m *MemoryStore) GetDueWebhookDeliveries(ctx context.Context, arg GetDueWebhookDeliveriesParams) ([]WebhookDelivery, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	deliveries := []WebhookDelivery{}
+	for _, delivery := range m.deliveries {
+		if delivery.DeliveredAt.Valid || delivery.DeadAt.Valid || delivery.NextAttemptAt.After(arg.Now) {
+			continue
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	sort.Slice(deliveries, func(i, j int) bool {
+		return deliveries[i].NextAttemptAt.Before(deliveries[j].NextAttemptAt)
+	})
+	if len(deliveries) > int(arg.Limit) {
+		deliveries = deliveries[:arg.Limit]
+	}
+	return deliveries, nil
+}
+
+func (m *MemoryStore) GetDeadWebhookDeliveries(ctx context.Context, arg GetDeadWebhookDeliveriesParams) ([]WebhookDelivery, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	deliveries := []WebhookDelivery{}
+	for _, delivery := range m.deliveries {
+		if delivery.WebhookID != arg.WebhookID || !delivery.DeadAt.Valid {
+			continue
+		}
+		if !pastCursor(delivery.DeadAt.Time, delivery.ID, arg.After, arg.Desc) {
+			continue
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	sort.Slice(deliveries, func(i, j int) bool {
+		a, b := deliveries[i], deliveries[j]
+		return keyLess(a.DeadAt.Time, a.ID, b.DeadAt.Time, b.ID, arg.Desc)
+	})
+	if len(deliveries) > int(arg.Limit) {
+		deliveries = deliveries[:arg.Limit]
+	}
+	return deliveries, nil
+}
+
+func (m *MemoryStore) MarkWebhookDeliverySucceeded(ctx context.Context, id uuid.UUID) (WebhookDelivery, error) {
+	return m.updateWebhookDelivery(id, func(delivery *WebhookDelivery) bool {
+		delivery.Attempts++
+		delivery.LastError = ""
+		delivery.DeliveredAt = sql.NullTime{Time: delivery.UpdatedAt, Valid: true}
+		return true
+	})
+}
+
+func (m *MemoryStore) MarkWebhookDeliveryFailed(ctx context.Context, arg MarkWebhookDeliveryFailedParams) (WebhookDelivery, error) {
+	return m.updateWebhookDelivery(arg.ID, func(delivery *WebhookDelivery) bool {
+		delivery.Attempts++
+		delivery.LastError = arg.LastError
+		delivery.NextAttemptAt = arg.NextAttemptAt
+		delivery.DeadAt = arg.DeadAt
+		return true
+	})
+}
+
+func (m *MemoryStore) RetryWebhookDelivery(ctx context.Context, arg RetryWebhookDeliveryParams) (WebhookDelivery, error) {
+	return m.updateWebhookDelivery(arg.ID, func(delivery *WebhookDelivery) bool {
+		if delivery.WebhookID != arg.WebhookID || !delivery.DeadAt.Valid {
+			return false
+		}
+		delivery.Attempts = 0
+		delivery.DeadAt = sql.NullTime{}
+		delivery.NextAttemptAt = delivery.UpdatedAt
+		return true
+	})
+}
+
+// updateWebhookDelivery is updateFeed for deliveries, except fn can report
+// that the delivery doesn't match, which is treated as not found
+func (m *MemoryStore) updateWebhookDelivery(id uuid.UUID, fn func(delivery *WebhookDelivery) bool) (WebhookDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delivery, ok := m.deliveries[id]
+	if !ok {
+		return WebhookDelivery{}, sql.ErrNoRows
+	}
+	delivery.UpdatedAt = time.Now().UTC()
+	if !fn(&delivery) {
+		return WebhookDelivery{}, sql.ErrNoRows
+	}
+	m.deliveries[id] = delivery
+	return delivery, nil
+}
+
+func sortWebhooksOldestFirst(webhooks []Webhook) {
+	sort.Slice(webhooks, func(i, j int) bool {
+		return webhooks[i].CreatedAt.Before(webhooks[j].CreatedAt)
+	})
+}
+
+func (m *MemoryStore) GetSetting(ctx context.Context, key string) (string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	value, ok := m.settings[key]
+	if !ok {
+		return "", sql.ErrNoRows
+	}
+	return value, nil
+}
+
+func (m *MemoryStore) SetSetting(ctx context.Context, arg SetSettingParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.settings[arg.Key] = arg.Value
+	return nil
+}
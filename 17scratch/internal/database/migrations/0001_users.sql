@@ -0,0 +1,11 @@
+-- +migrate Up
+CREATE TABLE users (
+	id TEXT PRIMARY KEY,
+	created_at DATETIME NOT NULL,
+	updated_at DATETIME NOT NULL,
+	name TEXT NOT NULL,
+	api_key TEXT NOT NULL UNIQUE
+);
+
+-- +migrate Down
+DROP TABLE users;
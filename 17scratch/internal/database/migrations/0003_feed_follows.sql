@@ -0,0 +1,12 @@
+-- +migrate Up
+CREATE TABLE feed_follows (
+	id TEXT PRIMARY KEY,
+	created_at DATETIME NOT NULL,
+	updated_at DATETIME NOT NULL,
+	user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	feed_id TEXT NOT NULL REFERENCES feeds(id) ON DELETE CASCADE,
+	UNIQUE (user_id, feed_id)
+);
+
+-- +migrate Down
+DROP TABLE feed_follows;
@@ -0,0 +1,5 @@
+-- +migrate Up
+ALTER TABLE feeds ADD COLUMN last_fetched_at DATETIME;
+
+-- +migrate Down
+ALTER TABLE feeds DROP COLUMN last_fetched_at;
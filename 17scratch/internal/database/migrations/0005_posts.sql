@@ -0,0 +1,19 @@
+-- +migrate Up
+CREATE TABLE posts (
+	id TEXT PRIMARY KEY,
+	created_at DATETIME NOT NULL,
+	updated_at DATETIME NOT NULL,
+	title TEXT NOT NULL,
+	url TEXT NOT NULL,
+	description TEXT NOT NULL,
+	published_at DATETIME NOT NULL,
+	guid TEXT NOT NULL,
+	feed_id TEXT NOT NULL REFERENCES feeds(id) ON DELETE CASCADE,
+	UNIQUE (feed_id, guid)
+);
+-- some feeds only give items a guid, so only dedupe on url when there is one
+CREATE UNIQUE INDEX posts_feed_id_url_idx ON posts (feed_id, url) WHERE url != '';
+CREATE INDEX posts_feed_id_published_at_idx ON posts (feed_id, published_at);
+
+-- +migrate Down
+DROP TABLE posts;
@@ -0,0 +1,15 @@
+-- +migrate Up
+ALTER TABLE feeds ADD COLUMN etag TEXT NOT NULL DEFAULT '';
+ALTER TABLE feeds ADD COLUMN last_modified TEXT NOT NULL DEFAULT '';
+ALTER TABLE feeds ADD COLUMN failure_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE feeds ADD COLUMN last_error TEXT NOT NULL DEFAULT '';
+ALTER TABLE feeds ADD COLUMN next_fetch_at DATETIME;
+ALTER TABLE feeds ADD COLUMN dead_at DATETIME;
+
+-- +migrate Down
+ALTER TABLE feeds DROP COLUMN dead_at;
+ALTER TABLE feeds DROP COLUMN next_fetch_at;
+ALTER TABLE feeds DROP COLUMN last_error;
+ALTER TABLE feeds DROP COLUMN failure_count;
+ALTER TABLE feeds DROP COLUMN last_modified;
+ALTER TABLE feeds DROP COLUMN etag;
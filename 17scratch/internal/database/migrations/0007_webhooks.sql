@@ -0,0 +1,31 @@
+-- +migrate Up
+CREATE TABLE webhooks (
+	id TEXT PRIMARY KEY,
+	created_at DATETIME NOT NULL,
+	updated_at DATETIME NOT NULL,
+	user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	url TEXT NOT NULL,
+	secret TEXT NOT NULL
+);
+CREATE INDEX webhooks_user_id_idx ON webhooks (user_id);
+
+CREATE TABLE webhook_deliveries (
+	id TEXT PRIMARY KEY,
+	created_at DATETIME NOT NULL,
+	updated_at DATETIME NOT NULL,
+	webhook_id TEXT NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+	post_id TEXT NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+	attempts INTEGER NOT NULL DEFAULT 0,
+	last_error TEXT NOT NULL DEFAULT '',
+	next_attempt_at DATETIME NOT NULL,
+	delivered_at DATETIME,
+	dead_at DATETIME,
+	UNIQUE (webhook_id, post_id)
+);
+-- the dispatcher only ever looks at deliveries still waiting to go out
+CREATE INDEX webhook_deliveries_pending_idx ON webhook_deliveries (next_attempt_at)
+	WHERE delivered_at IS NULL AND dead_at IS NULL;
+
+-- +migrate Down
+DROP TABLE webhook_deliveries;
+DROP TABLE webhooks;
@@ -0,0 +1,17 @@
+-- +migrate Up
+-- a row only exists once a user has read or starred the post, so a post
+-- without one is unread
+CREATE TABLE post_states (
+	user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	post_id TEXT NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+	created_at DATETIME NOT NULL,
+	updated_at DATETIME NOT NULL,
+	read_at DATETIME,
+	starred_at DATETIME,
+	PRIMARY KEY (user_id, post_id)
+);
+CREATE INDEX post_states_starred_idx ON post_states (user_id, starred_at)
+	WHERE starred_at IS NOT NULL;
+
+-- +migrate Down
+DROP TABLE post_states;
@@ -0,0 +1,6 @@
+-- +migrate Up
+-- summary is the plain text of the description, cut short for previews
+ALTER TABLE posts ADD COLUMN summary TEXT NOT NULL DEFAULT '';
+
+-- +migrate Down
+ALTER TABLE posts DROP COLUMN summary;
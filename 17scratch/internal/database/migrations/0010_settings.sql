@@ -0,0 +1,11 @@
+-- +migrate Up
+-- settings holds the little state the server keeps about itself, like which
+-- version of the sanitizer the stored posts went through
+CREATE TABLE settings (
+	key TEXT PRIMARY KEY,
+	value TEXT NOT NULL,
+	updated_at DATETIME NOT NULL
+);
+
+-- +migrate Down
+DROP TABLE settings;
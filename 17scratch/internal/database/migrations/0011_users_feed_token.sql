@@ -0,0 +1,10 @@
+-- +migrate Up
+-- feed_token is the unguessable part of the url a user's timeline is
+-- published at, existing users get one here
+ALTER TABLE users ADD COLUMN feed_token TEXT NOT NULL DEFAULT '';
+UPDATE users SET feed_token = lower(hex(randomblob(32)));
+CREATE UNIQUE INDEX users_feed_token_idx ON users (feed_token);
+
+-- +migrate Down
+DROP INDEX users_feed_token_idx;
+ALTER TABLE users DROP COLUMN feed_token;
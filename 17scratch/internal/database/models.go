@@ -0,0 +1,94 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type User struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Name      string
+	ApiKey    string
+	// FeedToken is the secret in the urls the user's timeline is published at
+	FeedToken string
+}
+
+type Feed struct {
+	ID            uuid.UUID
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	Name          string
+	Url           string
+	UserID        uuid.UUID
+	LastFetchedAt sql.NullTime
+	// validators from the last successful fetch, sent back to make it conditional
+	Etag         string
+	LastModified string
+	FailureCount int32
+	LastError    string
+	// NextFetchAt is only set while backing off after failures
+	NextFetchAt sql.NullTime
+	// DeadAt is set once a feed has failed too many times in a row
+	DeadAt sql.NullTime
+}
+
+type FeedFollow struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	UserID    uuid.UUID
+	FeedID    uuid.UUID
+}
+
+type Post struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Title       string
+	Url         string
+	Description string
+	PublishedAt time.Time
+	Guid        string
+	FeedID      uuid.UUID
+	Summary     string
+}
+
+// PostState is a user's read and starred state of a post. Posts the user
+// hasn't touched have none.
+type PostState struct {
+	UserID    uuid.UUID
+	PostID    uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	ReadAt    sql.NullTime
+	StarredAt sql.NullTime
+}
+
+type Webhook struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	UserID    uuid.UUID
+	Url       string
+	// Secret signs every payload sent to Url
+	Secret string
+}
+
+type WebhookDelivery struct {
+	ID            uuid.UUID
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	WebhookID     uuid.UUID
+	PostID        uuid.UUID
+	Attempts      int32
+	LastError     string
+	NextAttemptAt time.Time
+	DeliveredAt   sql.NullTime
+	// DeadAt is set once the delivery has failed too many times, which puts
+	// it on the webhook's dead letter list
+	DeadAt sql.NullTime
+}
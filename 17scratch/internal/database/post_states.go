@@ -0,0 +1,150 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// the states GetPostsForUser can filter by
+const (
+	PostStateUnread  = "unread"
+	PostStateRead    = "read"
+	PostStateStarred = "starred"
+)
+
+const postStateColumns = `user_id, post_id, created_at, updated_at, read_at, starred_at`
+
+func scanPostState(row rowScanner) (PostState, error) {
+	var i PostState
+	err := row.Scan(
+		&i.UserID,
+		&i.PostID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.ReadAt,
+		&i.StarredAt,
+	)
+	return i, err
+}
+
+// setting a flag that's already set keeps the time it was first set
+const setPostRead = `
+INSERT INTO post_states (user_id, post_id, created_at, updated_at, read_at)
+VALUES (?1, ?2, ?3, ?3, ?4)
+ON CONFLICT (user_id, post_id) DO UPDATE SET
+	read_at = CASE WHEN excluded.read_at IS NULL THEN NULL ELSE coalesce(post_states.read_at, excluded.read_at) END,
+	updated_at = excluded.updated_at
+RETURNING ` + postStateColumns
+
+type SetPostReadParams struct {
+	UserID uuid.UUID
+	PostID uuid.UUID
+	Read   bool
+}
+
+func (q *Queries) SetPostRead(ctx context.Context, arg SetPostReadParams) (PostState, error) {
+	now := time.Now().UTC()
+	return scanPostState(q.db.QueryRowContext(ctx, setPostRead,
+		arg.UserID,
+		arg.PostID,
+		now,
+		sql.NullTime{Time: now, Valid: arg.Read},
+	))
+}
+
+const setPostStarred = `
+INSERT INTO post_states (user_id, post_id, created_at, updated_at, starred_at)
+VALUES (?1, ?2, ?3, ?3, ?4)
+ON CONFLICT (user_id, post_id) DO UPDATE SET
+	starred_at = CASE WHEN excluded.starred_at IS NULL THEN NULL ELSE coalesce(post_states.starred_at, excluded.starred_at) END,
+	updated_at = excluded.updated_at
+RETURNING ` + postStateColumns
+
+type SetPostStarredParams struct {
+	UserID  uuid.UUID
+	PostID  uuid.UUID
+	Starred bool
+}
+
+func (q *Queries) SetPostStarred(ctx context.Context, arg SetPostStarredParams) (PostState, error) {
+	now := time.Now().UTC()
+	return scanPostState(q.db.QueryRowContext(ctx, setPostStarred,
+		arg.UserID,
+		arg.PostID,
+		now,
+		sql.NullTime{Time: now, Valid: arg.Starred},
+	))
+}
+
+const markPostsRead = `
+INSERT INTO post_states (user_id, post_id, created_at, updated_at, read_at)
+SELECT ?1, posts.id, ?4, ?4, ?4 FROM posts
+JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+WHERE feed_follows.user_id = ?1
+	AND (?2 IS NULL OR posts.feed_id = ?2)
+	AND (?3 IS NULL OR posts.published_at < ?3)
+ON CONFLICT (user_id, post_id) DO UPDATE SET read_at = excluded.read_at, updated_at = excluded.updated_at
+	WHERE post_states.read_at IS NULL
+`
+
+type MarkPostsReadParams struct {
+	UserID uuid.UUID
+	FeedID uuid.NullUUID
+	// Before only marks posts published before it
+	Before sql.NullTime
+}
+
+// MarkPostsRead marks the unread posts from the feeds the user follows read
+// and returns how many there were
+func (q *Queries) MarkPostsRead(ctx context.Context, arg MarkPostsReadParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, markPostsRead,
+		arg.UserID,
+		arg.FeedID,
+		arg.Before,
+		time.Now().UTC(),
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getPostStates = `
+SELECT ` + postStateColumns + ` FROM post_states
+WHERE user_id = ? AND post_id IN (SELECT value FROM json_each(?))
+`
+
+type GetPostStatesParams struct {
+	UserID  uuid.UUID
+	PostIDs []uuid.UUID
+}
+
+// GetPostStates returns the user's state of those of the posts that have
+// one
+func (q *Queries) GetPostStates(ctx context.Context, arg GetPostStatesParams) ([]PostState, error) {
+	// the ids go in as a JSON array so the query doesn't depend on how many
+	// there are
+	postIDs, err := json.Marshal(arg.PostIDs)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := q.db.QueryContext(ctx, getPostStates, arg.UserID, string(postIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []PostState{}
+	for rows.Next() {
+		i, err := scanPostState(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	return items, rows.Err()
+}
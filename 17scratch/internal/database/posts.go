@@ -0,0 +1,203 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const postColumns = `posts.id, posts.created_at, posts.updated_at, posts.title, posts.url,
+	posts.description, posts.published_at, posts.guid, posts.feed_id, posts.summary`
+
+func scanPost(row rowScanner) (Post, error) {
+	var i Post
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Title,
+		&i.Url,
+		&i.Description,
+		&i.PublishedAt,
+		&i.Guid,
+		&i.FeedID,
+		&i.Summary,
+	)
+	return i, err
+}
+
+func (q *Queries) queryPosts(ctx context.Context, query string, args ...interface{}) ([]Post, error) {
+	rows, err := q.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []Post{}
+	for rows.Next() {
+		i, err := scanPost(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	return items, rows.Err()
+}
+
+const createPost = `
+INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, guid, feed_id, summary)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+RETURNING ` + postColumns
+
+type CreatePostParams struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Title       string
+	Url         string
+	Description string
+	PublishedAt time.Time
+	Guid        string
+	FeedID      uuid.UUID
+	Summary     string
+}
+
+// CreatePost fails with a unique violation (see IsUniqueViolation) when the
+// feed already has a post with the same guid or url.
+func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, error) {
+	return scanPost(q.db.QueryRowContext(ctx, createPost,
+		arg.ID,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+		arg.Title,
+		arg.Url,
+		arg.Description,
+		arg.PublishedAt,
+		arg.Guid,
+		arg.FeedID,
+		arg.Summary,
+	))
+}
+
+const updatePostContent = `
+UPDATE posts SET description = ?, summary = ?, updated_at = ?
+WHERE id = ?
+RETURNING ` + postColumns
+
+type UpdatePostContentParams struct {
+	ID          uuid.UUID
+	Description string
+	Summary     string
+	UpdatedAt   time.Time
+}
+
+// UpdatePostContent replaces what's stored of a post's content, e.g. after
+// the sanitizer's policy changed
+func (q *Queries) UpdatePostContent(ctx context.Context, arg UpdatePostContentParams) (Post, error) {
+	return scanPost(q.db.QueryRowContext(ctx, updatePostContent,
+		arg.Description,
+		arg.Summary,
+		arg.UpdatedAt,
+		arg.ID,
+	))
+}
+
+const getPostsForUser = `
+SELECT ` + postColumns + ` FROM posts
+JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+LEFT JOIN post_states ON post_states.post_id = posts.id AND post_states.user_id = ?1
+WHERE feed_follows.user_id = ?1
+	AND (?2 IS NULL OR posts.feed_id = ?2)
+	AND (?3 IS NULL OR posts.published_at >= ?3)
+	AND (?4 IS NULL OR posts.published_at < ?4)
+	AND (?5 = '' OR posts.title LIKE ?5 ESCAPE '\')
+	AND (?6 IS NULL OR (posts.published_at, posts.id) %s (?6, ?7))
+	AND (?9 = ''
+		OR (?9 = 'unread' AND post_states.read_at IS NULL)
+		OR (?9 = 'read' AND post_states.read_at IS NOT NULL)
+		OR (?9 = 'starred' AND post_states.starred_at IS NOT NULL))
+ORDER BY posts.published_at %s, posts.id %s
+LIMIT ?8
+`
+
+type GetPostsForUserParams struct {
+	UserID uuid.UUID
+	FeedID uuid.NullUUID
+	// Since and Before bound published_at, Since inclusive and Before not
+	Since  sql.NullTime
+	Before sql.NullTime
+	// Query only returns posts whose title contains it when set
+	Query string
+	// State is one of the PostState constants, or empty for every post
+	State string
+	After Cursor
+	// Desc lists the newest posts first
+	Desc  bool
+	Limit int32
+}
+
+// GetPostsForUser pages through the posts from the feeds the user follows,
+// sorted by when they were published
+func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams) ([]Post, error) {
+	return q.queryPosts(ctx, pageQuery(getPostsForUser, arg.Desc),
+		arg.UserID,
+		arg.FeedID,
+		arg.Since,
+		arg.Before,
+		containsPattern(arg.Query),
+		arg.After.nullTime(),
+		arg.After.ID,
+		arg.Limit,
+		arg.State,
+	)
+}
+
+const getPostByID = `
+SELECT ` + postColumns + ` FROM posts WHERE posts.id = ?
+`
+
+func (q *Queries) GetPostByID(ctx context.Context, id uuid.UUID) (Post, error) {
+	return scanPost(q.db.QueryRowContext(ctx, getPostByID, id))
+}
+
+const getPosts = `
+SELECT ` + postColumns + ` FROM posts
+WHERE (?1 IS NULL OR (posts.created_at, posts.id) > (?1, ?2))
+ORDER BY posts.created_at ASC, posts.id ASC
+LIMIT ?3
+`
+
+type GetPostsParams struct {
+	After Cursor
+	Limit int32
+}
+
+// GetPosts pages through every stored post in the order they were stored,
+// e.g. to build the search index
+func (q *Queries) GetPosts(ctx context.Context, arg GetPostsParams) ([]Post, error) {
+	return q.queryPosts(ctx, getPosts, arg.After.nullTime(), arg.After.ID, arg.Limit)
+}
+
+const getPostsForUserCreatedAfter = `
+SELECT ` + postColumns + ` FROM posts
+JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+WHERE feed_follows.user_id = ?1
+	AND (?2 IS NULL OR (posts.created_at, posts.id) > (?2, ?3))
+ORDER BY posts.created_at ASC, posts.id ASC
+LIMIT ?4
+`
+
+type GetPostsForUserCreatedAfterParams struct {
+	UserID uuid.UUID
+	// After is the created_at and id of the last post the client has
+	After Cursor
+	Limit int32
+}
+
+// GetPostsForUserCreatedAfter returns posts in the order they were stored,
+// oldest first, so a client can catch up on what it missed a page at a time
+func (q *Queries) GetPostsForUserCreatedAfter(ctx context.Context, arg GetPostsForUserCreatedAfterParams) ([]Post, error) {
+	return q.queryPosts(ctx, getPostsForUserCreatedAfter, arg.UserID, arg.After.nullTime(), arg.After.ID, arg.Limit)
+}
@@ -0,0 +1,123 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// Store is everything the server needs from storage. Missing rows are
+// reported as sql.ErrNoRows by every implementation.
+type Store interface {
+	CreateUser(ctx context.Context, arg CreateUserParams) (User, error)
+	GetUserByAPIKey(ctx context.Context, apiKey string) (User, error)
+	GetUserByID(ctx context.Context, id uuid.UUID) (User, error)
+	GetUserByFeedToken(ctx context.Context, feedToken string) (User, error)
+
+	CreateFeed(ctx context.Context, arg CreateFeedParams) (Feed, error)
+	GetFeeds(ctx context.Context, arg GetFeedsParams) ([]Feed, error)
+	GetFeedByURL(ctx context.Context, url string) (Feed, error)
+	GetFeedsForUser(ctx context.Context, userID uuid.UUID) ([]Feed, error)
+	GetFeedByID(ctx context.Context, id uuid.UUID) (Feed, error)
+	GetNextFeedsToFetch(ctx context.Context, arg GetNextFeedsToFetchParams) ([]Feed, error)
+	MarkFeedFetched(ctx context.Context, id uuid.UUID) (Feed, error)
+	MarkFeedFetchSucceeded(ctx context.Context, arg MarkFeedFetchSucceededParams) (Feed, error)
+	MarkFeedFetchFailed(ctx context.Context, arg MarkFeedFetchFailedParams) (Feed, error)
+
+	CreateFeedFollow(ctx context.Context, arg CreateFeedFollowParams) (FeedFollow, error)
+	GetFeedFollows(ctx context.Context, arg GetFeedFollowsParams) ([]FeedFollow, error)
+	DeleteFeedFollow(ctx context.Context, arg DeleteFeedFollowParams) error
+
+	CreatePost(ctx context.Context, arg CreatePostParams) (Post, error)
+	UpdatePostContent(ctx context.Context, arg UpdatePostContentParams) (Post, error)
+	GetPostByID(ctx context.Context, id uuid.UUID) (Post, error)
+	GetPosts(ctx context.Context, arg GetPostsParams) ([]Post, error)
+	GetPostsForUser(ctx context.Context, arg GetPostsForUserParams) ([]Post, error)
+	GetPostsForUserCreatedAfter(ctx context.Context, arg GetPostsForUserCreatedAfterParams) ([]Post, error)
+
+	SetPostRead(ctx context.Context, arg SetPostReadParams) (PostState, error)
+	SetPostStarred(ctx context.Context, arg SetPostStarredParams) (PostState, error)
+	MarkPostsRead(ctx context.Context, arg MarkPostsReadParams) (int64, error)
+	GetPostStates(ctx context.Context, arg GetPostStatesParams) ([]PostState, error)
+
+	CreateWebhook(ctx context.Context, arg CreateWebhookParams) (Webhook, error)
+	GetWebhooksForUser(ctx context.Context, arg GetWebhooksForUserParams) ([]Webhook, error)
+	GetWebhookByID(ctx context.Context, id uuid.UUID) (Webhook, error)
+	GetWebhooksForFeed(ctx context.Context, feedID uuid.UUID) ([]Webhook, error)
+	DeleteWebhook(ctx context.Context, arg DeleteWebhookParams) error
+
+	CreateWebhookDelivery(ctx context.Context, arg CreateWebhookDeliveryParams) (WebhookDelivery, error)
+	GetDueWebhookDeliveries(ctx context.Context, arg GetDueWebhookDeliveriesParams) ([]WebhookDelivery, error)
+	GetDeadWebhookDeliveries(ctx context.Context, arg GetDeadWebhookDeliveriesParams) ([]WebhookDelivery, error)
+	MarkWebhookDeliverySucceeded(ctx context.Context, id uuid.UUID) (WebhookDelivery, error)
+	MarkWebhookDeliveryFailed(ctx context.Context, arg MarkWebhookDeliveryFailedParams) (WebhookDelivery, error)
+	RetryWebhookDelivery(ctx context.Context, arg RetryWebhookDeliveryParams) (WebhookDelivery, error)
+
+	GetSetting(ctx context.Context, key string) (string, error)
+	SetSetting(ctx context.Context, arg SetSettingParams) error
+
+	// Ping reports whether storage can currently serve queries
+	Ping(ctx context.Context) error
+	Close() error
+}
+
+const (
+	DriverSQLite = "sqlite"
+	DriverMemory = "memory"
+)
+
+var (
+	ErrUniqueViolation     = errors.New("unique constraint violated")
+	ErrForeignKeyViolation = errors.New("foreign key constraint violated")
+)
+
+// NewStore opens the backend selected by driver. dsn is ignored by the
+// memory backend.
+func NewStore(ctx context.Context, driver, dsn string) (Store, error) {
+	switch driver {
+	case DriverSQLite:
+		return NewSQLiteStore(ctx, dsn)
+	case DriverMemory:
+		return NewMemoryStore(), nil
+	}
+	return nil, fmt.Errorf("unknown database driver %q", driver)
+}
+
+// SQLiteStore is the persistent Store. The queries live on the embedded
+// *Queries, it just owns the connection.
+type SQLiteStore struct {
+	*Queries
+	db *sql.DB
+}
+
+var _ Store = (*SQLiteStore)(nil)
+
+// NewSQLiteStore opens the database and brings its schema up to date
+func NewSQLiteStore(ctx context.Context, dsn string) (*SQLiteStore, error) {
+	db, err := Open(dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := Migrate(ctx, db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("migrate: %w", err)
+	}
+	return &SQLiteStore{
+		Queries: New(db),
+		db:      db,
+	}, nil
+}
+
+// Ping runs a real query rather than just checking the connection, so a
+// missing or locked database file shows up too
+func (s *SQLiteStore) Ping(ctx context.Context) error {
+	var n int
+	return s.db.QueryRowContext(ctx, "SELECT count(*) FROM schema_migrations").Scan(&n)
+}
+
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,72 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const userColumns = `id, created_at, updated_at, name, api_key, feed_token`
+
+func scanUser(row rowScanner) (User, error) {
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.ApiKey,
+		&i.FeedToken,
+	)
+	return i, err
+}
+
+const createUser = `
+INSERT INTO users (id, created_at, updated_at, name, api_key, feed_token)
+VALUES (?, ?, ?, ?, ?, ?)
+RETURNING ` + userColumns
+
+type CreateUserParams struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Name      string
+	ApiKey    string
+	FeedToken string
+}
+
+func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
+	return scanUser(q.db.QueryRowContext(ctx, createUser,
+		arg.ID,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+		arg.Name,
+		arg.ApiKey,
+		arg.FeedToken,
+	))
+}
+
+const getUserByAPIKey = `
+SELECT ` + userColumns + ` FROM users WHERE api_key = ?
+`
+
+func (q *Queries) GetUserByAPIKey(ctx context.Context, apiKey string) (User, error) {
+	return scanUser(q.db.QueryRowContext(ctx, getUserByAPIKey, apiKey))
+}
+
+const getUserByID = `
+SELECT ` + userColumns + ` FROM users WHERE id = ?
+`
+
+func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (User, error) {
+	return scanUser(q.db.QueryRowContext(ctx, getUserByID, id))
+}
+
+const getUserByFeedToken = `
+SELECT ` + userColumns + ` FROM users WHERE feed_token = ?
+`
+
+func (q *Queries) GetUserByFeedToken(ctx context.Context, feedToken string) (User, error) {
+	return scanUser(q.db.QueryRowContext(ctx, getUserByFeedToken, feedToken))
+}
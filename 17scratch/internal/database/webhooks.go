@@ -0,0 +1,288 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const webhookColumns = `webhooks.id, webhooks.created_at, webhooks.updated_at, webhooks.user_id,
+	webhooks.url, webhooks.secret`
+
+func scanWebhook(row rowScanner) (Webhook, error) {
+	var i Webhook
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Url,
+		&i.Secret,
+	)
+	return i, err
+}
+
+func (q *Queries) queryWebhooks(ctx context.Context, query string, args ...interface{}) ([]Webhook, error) {
+	rows, err := q.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []Webhook{}
+	for rows.Next() {
+		i, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	return items, rows.Err()
+}
+
+const createWebhook = `
+INSERT INTO webhooks (id, created_at, updated_at, user_id, url, secret)
+VALUES (?, ?, ?, ?, ?, ?)
+RETURNING ` + webhookColumns
+
+type CreateWebhookParams struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	UserID    uuid.UUID
+	Url       string
+	Secret    string
+}
+
+func (q *Queries) CreateWebhook(ctx context.Context, arg CreateWebhookParams) (Webhook, error) {
+	return scanWebhook(q.db.QueryRowContext(ctx, createWebhook,
+		arg.ID,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+		arg.UserID,
+		arg.Url,
+		arg.Secret,
+	))
+}
+
+const getWebhooksForUser = `
+SELECT ` + webhookColumns + ` FROM webhooks
+WHERE user_id = ?1
+	AND (?2 IS NULL OR (created_at, id) %s (?2, ?3))
+ORDER BY created_at %s, id %s
+LIMIT ?4
+`
+
+type GetWebhooksForUserParams struct {
+	UserID uuid.UUID
+	After  Cursor
+	// Desc lists the newest webhooks first
+	Desc  bool
+	Limit int32
+}
+
+func (q *Queries) GetWebhooksForUser(ctx context.Context, arg GetWebhooksForUserParams) ([]Webhook, error) {
+	return q.queryWebhooks(ctx, pageQuery(getWebhooksForUser, arg.Desc),
+		arg.UserID,
+		arg.After.nullTime(),
+		arg.After.ID,
+		arg.Limit,
+	)
+}
+
+const getWebhookByID = `
+SELECT ` + webhookColumns + ` FROM webhooks WHERE id = ?
+`
+
+func (q *Queries) GetWebhookByID(ctx context.Context, id uuid.UUID) (Webhook, error) {
+	return scanWebhook(q.db.QueryRowContext(ctx, getWebhookByID, id))
+}
+
+const getWebhooksForFeed = `
+SELECT ` + webhookColumns + ` FROM webhooks
+JOIN feed_follows ON feed_follows.user_id = webhooks.user_id
+WHERE feed_follows.feed_id = ?
+ORDER BY webhooks.created_at
+`
+
+// GetWebhooksForFeed returns the webhooks of every user following the feed
+func (q *Queries) GetWebhooksForFeed(ctx context.Context, feedID uuid.UUID) ([]Webhook, error) {
+	return q.queryWebhooks(ctx, getWebhooksForFeed, feedID)
+}
+
+const deleteWebhook = `
+DELETE FROM webhooks WHERE id = ? AND user_id = ?
+RETURNING id
+`
+
+type DeleteWebhookParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+// DeleteWebhook returns sql.ErrNoRows if the webhook doesn't exist or
+// belongs to someone else. Its pending deliveries go with it.
+func (q *Queries) DeleteWebhook(ctx context.Context, arg DeleteWebhookParams) error {
+	var id uuid.UUID
+	return q.db.QueryRowContext(ctx, deleteWebhook, arg.ID, arg.UserID).Scan(&id)
+}
+
+const webhookDeliveryColumns = `id, created_at, updated_at, webhook_id, post_id, attempts,
+	last_error, next_attempt_at, delivered_at, dead_at`
+
+func scanWebhookDelivery(row rowScanner) (WebhookDelivery, error) {
+	var i WebhookDelivery
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.WebhookID,
+		&i.PostID,
+		&i.Attempts,
+		&i.LastError,
+		&i.NextAttemptAt,
+		&i.DeliveredAt,
+		&i.DeadAt,
+	)
+	return i, err
+}
+
+func (q *Queries) queryWebhookDeliveries(ctx context.Context, query string, args ...interface{}) ([]WebhookDelivery, error) {
+	rows, err := q.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []WebhookDelivery{}
+	for rows.Next() {
+		i, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	return items, rows.Err()
+}
+
+const createWebhookDelivery = `
+INSERT INTO webhook_deliveries (id, created_at, updated_at, webhook_id, post_id, next_attempt_at)
+VALUES (?, ?, ?, ?, ?, ?)
+RETURNING ` + webhookDeliveryColumns
+
+type CreateWebhookDeliveryParams struct {
+	ID            uuid.UUID
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	WebhookID     uuid.UUID
+	PostID        uuid.UUID
+	NextAttemptAt time.Time
+}
+
+func (q *Queries) CreateWebhookDelivery(ctx context.Context, arg CreateWebhookDeliveryParams) (WebhookDelivery, error) {
+	return scanWebhookDelivery(q.db.QueryRowContext(ctx, createWebhookDelivery,
+		arg.ID,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+		arg.WebhookID,
+		arg.PostID,
+		arg.NextAttemptAt,
+	))
+}
+
+const getDueWebhookDeliveries = `
+SELECT ` + webhookDeliveryColumns + ` FROM webhook_deliveries
+WHERE delivered_at IS NULL AND dead_at IS NULL AND next_attempt_at <= ?
+ORDER BY next_attempt_at
+LIMIT ?
+`
+
+type GetDueWebhookDeliveriesParams struct {
+	Now   time.Time
+	Limit int32
+}
+
+// GetDueWebhookDeliveries returns pending deliveries whose next attempt is
+// due, longest waiting first
+func (q *Queries) GetDueWebhookDeliveries(ctx context.Context, arg GetDueWebhookDeliveriesParams) ([]WebhookDelivery, error) {
+	return q.queryWebhookDeliveries(ctx, getDueWebhookDeliveries, arg.Now, arg.Limit)
+}
+
+const getDeadWebhookDeliveries = `
+SELECT ` + webhookDeliveryColumns + ` FROM webhook_deliveries
+WHERE webhook_id = ?1 AND dead_at IS NOT NULL
+	AND (?2 IS NULL OR (dead_at, id) %s (?2, ?3))
+ORDER BY dead_at %s, id %s
+LIMIT ?4
+`
+
+type GetDeadWebhookDeliveriesParams struct {
+	WebhookID uuid.UUID
+	After     Cursor
+	// Desc lists the deliveries given up on most recently first
+	Desc  bool
+	Limit int32
+}
+
+func (q *Queries) GetDeadWebhookDeliveries(ctx context.Context, arg GetDeadWebhookDeliveriesParams) ([]WebhookDelivery, error) {
+	return q.queryWebhookDeliveries(ctx, pageQuery(getDeadWebhookDeliveries, arg.Desc),
+		arg.WebhookID,
+		arg.After.nullTime(),
+		arg.After.ID,
+		arg.Limit,
+	)
+}
+
+const markWebhookDeliverySucceeded = `
+UPDATE webhook_deliveries SET attempts = attempts + 1, last_error = '', delivered_at = ?,
+	updated_at = ?
+WHERE id = ?
+RETURNING ` + webhookDeliveryColumns
+
+func (q *Queries) MarkWebhookDeliverySucceeded(ctx context.Context, id uuid.UUID) (WebhookDelivery, error) {
+	now := time.Now().UTC()
+	return scanWebhookDelivery(q.db.QueryRowContext(ctx, markWebhookDeliverySucceeded, now, now, id))
+}
+
+const markWebhookDeliveryFailed = `
+UPDATE webhook_deliveries SET attempts = attempts + 1, last_error = ?, next_attempt_at = ?,
+	dead_at = ?, updated_at = ?
+WHERE id = ?
+RETURNING ` + webhookDeliveryColumns
+
+type MarkWebhookDeliveryFailedParams struct {
+	ID            uuid.UUID
+	LastError     string
+	NextAttemptAt time.Time
+	DeadAt        sql.NullTime
+}
+
+func (q *Queries) MarkWebhookDeliveryFailed(ctx context.Context, arg MarkWebhookDeliveryFailedParams) (WebhookDelivery, error) {
+	return scanWebhookDelivery(q.db.QueryRowContext(ctx, markWebhookDeliveryFailed,
+		arg.LastError,
+		arg.NextAttemptAt,
+		arg.DeadAt,
+		time.Now().UTC(),
+		arg.ID,
+	))
+}
+
+const retryWebhookDelivery = `
+UPDATE webhook_deliveries SET attempts = 0, dead_at = NULL, next_attempt_at = ?, updated_at = ?
+WHERE id = ? AND webhook_id = ? AND dead_at IS NOT NULL
+RETURNING ` + webhookDeliveryColumns
+
+type RetryWebhookDeliveryParams struct {
+	ID        uuid.UUID
+	WebhookID uuid.UUID
+}
+
+// RetryWebhookDelivery takes a dead delivery off the dead letter list and
+// queues it to go out straight away. It returns sql.ErrNoRows if the delivery
+// isn't dead or belongs to another webhook.
+func (q *Queries) RetryWebhookDelivery(ctx context.Context, arg RetryWebhookDeliveryParams) (WebhookDelivery, error) {
+	now := time.Now().UTC()
+	return scanWebhookDelivery(q.db.QueryRowContext(ctx, retryWebhookDelivery, now, now, arg.ID, arg.WebhookID))
+}
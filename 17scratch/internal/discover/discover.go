@@ -0,0 +1,168 @@
+// Package discover finds the feed of a website. Given a page it looks for the
+// feeds the page links to with <link rel="alternate">, then tries the paths
+// feeds are commonly served from, and only accepts a candidate the feed
+// parser can read.
+package discover
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/anishakd4/rssagg/internal/feedparser"
+	"golang.org/x/net/html"
+)
+
+const (
+	// maxBodySize caps how much of a page or feed is read
+	maxBodySize = 10 << 20
+	// maxCandidates bounds the requests made for a single page
+	maxCandidates = 10
+)
+
+var ErrNoFeed = errors.New("discover: no feed found")
+
+// feedTypes are the link types that point at a feed
+var feedTypes = map[string]bool{
+	"application/rss+xml":   true,
+	"application/atom+xml":  true,
+	"application/feed+json": true,
+}
+
+// fallbackPaths are tried, in order, when a page doesn't link to its feed
+var fallbackPaths = []string{"/feed", "/rss.xml", "/atom.xml", "/feed.xml", "/index.xml", "/rss", "/feed.json"}
+
+// Result is the feed that was found and what it parsed to
+type Result struct {
+	URL  string
+	Feed feedparser.Feed
+}
+
+// Find returns the feed at pageURL when it's a feed itself, otherwise the
+// first feed the page leads to
+func Find(ctx context.Context, client *http.Client, pageURL string) (Result, error) {
+	body, finalURL, err := fetch(ctx, client, pageURL)
+	if err != nil {
+		return Result{}, err
+	}
+	if feed, err := feedparser.Parse(bytes.NewReader(body)); err == nil {
+		return Result{URL: pageURL, Feed: feed}, nil
+	}
+
+	candidates := linkedFeeds(body, finalURL)
+	for _, path := range fallbackPaths {
+		candidates = append(candidates, finalURL.ResolveReference(&url.URL{Path: path}).String())
+	}
+
+	tried := map[string]bool{pageURL: true, finalURL.String(): true}
+	attempts := 0
+	for _, candidate := range candidates {
+		if tried[candidate] {
+			continue
+		}
+		if attempts == maxCandidates || ctx.Err() != nil {
+			break
+		}
+		tried[candidate] = true
+		attempts++
+
+		body, _, err := fetch(ctx, client, candidate)
+		if err != nil {
+			continue
+		}
+		if feed, err := feedparser.Parse(bytes.NewReader(body)); err == nil {
+			return Result{URL: candidate, Feed: feed}, nil
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return Result{}, err
+	}
+	return Result{}, ErrNoFeed
+}
+
+// fetch returns the body and the url it came from after redirects, which
+// relative links resolve against
+func fetch(ctx context.Context, client *http.Client, rawURL string) ([]byte, *url.URL, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("User-Agent", "rssagg")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, nil, fmt.Errorf("discover: unexpected status: %s", resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBodySize))
+	if err != nil {
+		return nil, nil, err
+	}
+	return body, resp.Request.URL, nil
+}
+
+// linkedFeeds returns the feeds the page's head links to, in document order,
+// as absolute urls. A <base href> changes what they're relative to.
+func linkedFeeds(page []byte, base *url.URL) []string {
+	feeds := []string{}
+	z := html.NewTokenizer(bytes.NewReader(page))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return feeds
+		case html.StartTagToken, html.SelfClosingTagToken:
+			tok := z.Token()
+			switch tok.Data {
+			case "body":
+				// links to feeds belong in the head
+				return feeds
+			case "base":
+				if href := attr(tok, "href"); href != "" {
+					if u, err := base.Parse(href); err == nil {
+						base = u
+					}
+				}
+			case "link":
+				if !isFeedLink(tok) {
+					continue
+				}
+				if u, err := base.Parse(strings.TrimSpace(attr(tok, "href"))); err == nil {
+					feeds = append(feeds, u.String())
+				}
+			}
+		}
+	}
+}
+
+func isFeedLink(tok html.Token) bool {
+	if attr(tok, "href") == "" {
+		return false
+	}
+	isAlternate := false
+	for _, rel := range strings.Fields(strings.ToLower(attr(tok, "rel"))) {
+		if rel == "alternate" {
+			isAlternate = true
+		}
+	}
+	mediaType, _, err := mime.ParseMediaType(attr(tok, "type"))
+	return isAlternate && err == nil && feedTypes[mediaType]
+}
+
+func attr(tok html.Token, name string) string {
+	for _, a := range tok.Attr {
+		if a.Key == name {
+			return a.Val
+		}
+	}
+	return ""
+}
@@ -0,0 +1,88 @@
+package feedparser
+
+import (
+	"fmt"
+	"strings"
+)
+
+type atomFeed struct {
+	Title    string      `xml:"title"`
+	Subtitle string      `xml:"subtitle"`
+	Link     []atomLink  `xml:"link"`
+	Entry    []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+}
+
+type atomEntry struct {
+	Title     string     `xml:"title"`
+	ID        string     `xml:"id"`
+	Link      []atomLink `xml:"link"`
+	Summary   atomText   `xml:"summary"`
+	Content   atomText   `xml:"content"`
+	Published string     `xml:"published"`
+	Updated   string     `xml:"updated"`
+}
+
+// atomText is a text construct. Plain and escaped html content arrives as
+// character data, while xhtml content is inline markup.
+type atomText struct {
+	Type  string `xml:"type,attr"`
+	Text  string `xml:",chardata"`
+	Inner string `xml:",innerxml"`
+}
+
+func (t atomText) String() string {
+	if t.Type == "xhtml" {
+		return strings.TrimSpace(t.Inner)
+	}
+	return strings.TrimSpace(t.Text)
+}
+
+// alternateLink picks the rel="alternate" link, which is also what a link
+// without a rel means.
+func alternateLink(links []atomLink) string {
+	for _, link := range links {
+		if link.Rel == "" || link.Rel == "alternate" {
+			return strings.TrimSpace(link.Href)
+		}
+	}
+	return ""
+}
+
+func parseAtom(data []byte) (Feed, error) {
+	atom := atomFeed{}
+	if err := newDecoder(data).Decode(&atom); err != nil {
+		return Feed{}, fmt.Errorf("feedparser: atom: %w", err)
+	}
+
+	feed := Feed{
+		Title:       strings.TrimSpace(atom.Title),
+		Link:        alternateLink(atom.Link),
+		Description: strings.TrimSpace(atom.Subtitle),
+		Posts:       []Post{},
+	}
+	for _, entry := range atom.Entry {
+		post := Post{
+			Title:       strings.TrimSpace(entry.Title),
+			URL:         alternateLink(entry.Link),
+			Description: entry.Summary.String(),
+			GUID:        strings.TrimSpace(entry.ID),
+			PublishedAt: parseDate(entry.Published),
+		}
+		if post.Description == "" {
+			post.Description = entry.Content.String()
+		}
+		if post.PublishedAt.IsZero() {
+			post.PublishedAt = parseDate(entry.Updated)
+		}
+		if post.GUID == "" {
+			post.GUID = post.URL
+		}
+		feed.Posts = append(feed.Posts, post)
+	}
+	return feed, nil
+}
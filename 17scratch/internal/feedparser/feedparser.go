@@ -0,0 +1,153 @@
+package feedparser
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Feed is the format independent result of parsing a document
+type Feed struct {
+	Title       string
+	Link        string
+	Description string
+	Posts       []Post
+}
+
+// Post is a single RSS item, Atom entry or JSON Feed item. Any field can be empty and
+// PublishedAt is the zero time when the feed didn't give a usable date.
+type Post struct {
+	Title       string
+	URL         string
+	Description string
+	PublishedAt time.Time
+	GUID        string
+}
+
+var ErrUnknownFormat = errors.New("feedparser: document is not RSS, Atom or JSON Feed")
+
+// Parse detects the format of the document from its root element and decodes
+// it. RSS 2.0 (<rss><channel><item>), Atom (<feed><entry>) and JSON Feed 1.x,
+// which is recognised by starting with a {, are supported.
+func Parse(r io.Reader) (Feed, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return Feed{}, err
+	}
+
+	trimmed := bytes.TrimLeft(data, " \t\r\n\ufeff")
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		return parseJSONFeed(trimmed)
+	}
+
+	root, err := rootElement(data)
+	if err != nil {
+		return Feed{}, err
+	}
+	switch root {
+	case "rss":
+		return parseRSS(data)
+	case "feed":
+		return parseAtom(data)
+	}
+	return Feed{}, ErrUnknownFormat
+}
+
+func newDecoder(data []byte) *xml.Decoder {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	// real world feeds are full of undeclared entities like &nbsp;
+	decoder.Strict = false
+	decoder.Entity = xml.HTMLEntity
+	decoder.CharsetReader = charsetReader
+	return decoder
+}
+
+func rootElement(data []byte) (string, error) {
+	decoder := newDecoder(data)
+	for {
+		tok, err := decoder.Token()
+		if err == io.EOF {
+			return "", ErrUnknownFormat
+		}
+		if err != nil {
+			return "", fmt.Errorf("feedparser: %w", err)
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			return start.Name.Local, nil
+		}
+	}
+}
+
+// charsetReader handles the handful of non UTF-8 encodings that still show up
+// in feeds. Latin-1 maps byte for byte onto the first 256 code points, and so
+// does windows-1252 apart from the printable characters it puts in 0x80-0x9f.
+func charsetReader(charset string, input io.Reader) (io.Reader, error) {
+	var high *[32]rune
+	switch strings.ToLower(charset) {
+	case "utf-8", "utf8", "us-ascii", "ascii":
+		return input, nil
+	case "iso-8859-1", "iso8859-1", "latin1", "latin-1":
+	case "windows-1252", "cp1252":
+		high = &windows1252
+	default:
+		return nil, fmt.Errorf("feedparser: unsupported charset %q", charset)
+	}
+
+	data, err := io.ReadAll(input)
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 0, len(data))
+	for _, b := range data {
+		r := rune(b)
+		if high != nil && b >= 0x80 && b < 0xa0 {
+			r = high[b-0x80]
+		}
+		buf = utf8.AppendRune(buf, r)
+	}
+	return bytes.NewReader(buf), nil
+}
+
+// windows1252 is what windows-1252 has at 0x80-0x9f. The five bytes it leaves
+// undefined keep their Latin-1 meaning.
+var windows1252 = [32]rune{
+	'€', 0x81, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0x8d, 'Ž', 0x8f,
+	0x90, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0x9d, 'ž', 'Ÿ',
+}
+
+var dateLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC3339,
+	time.RFC3339Nano,
+	time.RFC822Z,
+	time.RFC822,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"Mon, 02 Jan 2006 15:04 -0700",
+	"2 Jan 2006 15:04:05 -0700",
+	"02 Jan 2006 15:04:05 MST",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// parseDate tries the layouts feeds commonly use and returns the zero time
+// if none of them match.
+func parseDate(s string) time.Time {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}
+	}
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC()
+		}
+	}
+	return time.Time{}
+}
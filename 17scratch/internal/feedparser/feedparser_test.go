@@ -0,0 +1,317 @@
+package feedparser
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func mustParse(t *testing.T, doc string) Feed {
+	t.Helper()
+	feed, err := Parse(strings.NewReader(doc))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	return feed
+}
+
+func wantPosts(t *testing.T, got, want []Post) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d posts, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		g, w := got[i], want[i]
+		if g.Title != w.Title || g.URL != w.URL || g.Description != w.Description || g.GUID != w.GUID || !g.PublishedAt.Equal(w.PublishedAt) {
+			t.Errorf("post %d\n got %+v\nwant %+v", i, g, w)
+		}
+	}
+}
+
+func TestParseRSS(t *testing.T) {
+	feed := mustParse(t, `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:content="http://purl.org/rss/1.0/modules/content/" xmlns:dc="http://purl.org/dc/elements/1.1/">
+<channel>
+	<title> Example </title>
+	<link>https://example.com/</link>
+	<description>All the examples</description>
+	<item>
+		<title>First</title>
+		<link>https://example.com/first</link>
+		<description>&lt;p&gt;Hello&nbsp;world&lt;/p&gt;</description>
+		<pubDate>Mon, 02 Jan 2006 15:04:05 GMT</pubDate>
+		<guid isPermaLink="false">first-guid</guid>
+	</item>
+	<item>
+		<title>Content encoded and dc:date</title>
+		<link>https://example.com/second</link>
+		<content:encoded><![CDATA[<p>Encoded</p>]]></content:encoded>
+		<dc:date>2006-01-03T10:00:00Z</dc:date>
+	</item>
+	<item>
+		<title>Permalink guid</title>
+		<guid>https://example.com/third</guid>
+	</item>
+	<item>
+		<title>Guid that isn't a link</title>
+		<guid isPermaLink="false">fourth</guid>
+	</item>
+	<item>
+		<title>No date</title>
+		<link>https://example.com/fifth</link>
+		<pubDate>sometime last week</pubDate>
+	</item>
+</channel>
+</rss>`)
+
+	if feed.Title != "Example" || feed.Link != "https://example.com/" || feed.Description != "All the examples" {
+		t.Errorf("feed = %q %q %q", feed.Title, feed.Link, feed.Description)
+	}
+	wantPosts(t, feed.Posts, []Post{
+		{
+			Title:       "First",
+			URL:         "https://example.com/first",
+			Description: "<p>Hello world</p>",
+			GUID:        "first-guid",
+			PublishedAt: time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC),
+		},
+		{
+			Title:       "Content encoded and dc:date",
+			URL:         "https://example.com/second",
+			Description: "<p>Encoded</p>",
+			// no guid, the link stands in for it
+			GUID:        "https://example.com/second",
+			PublishedAt: time.Date(2006, 1, 3, 10, 0, 0, 0, time.UTC),
+		},
+		{
+			Title: "Permalink guid",
+			URL:   "https://example.com/third",
+			GUID:  "https://example.com/third",
+		},
+		{
+			Title: "Guid that isn't a link",
+			GUID:  "fourth",
+		},
+		{
+			Title: "No date",
+			URL:   "https://example.com/fifth",
+			GUID:  "https://example.com/fifth",
+		},
+	})
+}
+
+func TestParseAtom(t *testing.T) {
+	feed := mustParse(t, `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+	<title>Atom example</title>
+	<subtitle>Entries</subtitle>
+	<link rel="self" href="https://example.com/atom.xml"/>
+	<link href="https://example.com/"/>
+	<entry>
+		<title>Summary wins</title>
+		<id>urn:uuid:1</id>
+		<link rel="edit" href="https://example.com/edit/1"/>
+		<link rel="alternate" href="https://example.com/1"/>
+		<summary>The summary</summary>
+		<content type="html">&lt;p&gt;The content&lt;/p&gt;</content>
+		<published>2006-01-02T15:04:05+07:00</published>
+		<updated>2006-01-05T00:00:00Z</updated>
+	</entry>
+	<entry>
+		<title>Content without summary</title>
+		<id>urn:uuid:2</id>
+		<link href="https://example.com/2"/>
+		<content type="xhtml"><div xmlns="http://www.w3.org/1999/xhtml"><p>Inline</p></div></content>
+		<updated>2006-01-05T00:00:00Z</updated>
+	</entry>
+	<entry>
+		<title>No id</title>
+		<link href="https://example.com/3"/>
+	</entry>
+</feed>`)
+
+	if feed.Title != "Atom example" || feed.Link != "https://example.com/" || feed.Description != "Entries" {
+		t.Errorf("feed = %q %q %q", feed.Title, feed.Link, feed.Description)
+	}
+	wantPosts(t, feed.Posts, []Post{
+		{
+			Title:       "Summary wins",
+			URL:         "https://example.com/1",
+			Description: "The summary",
+			GUID:        "urn:uuid:1",
+			PublishedAt: time.Date(2006, 1, 2, 8, 4, 5, 0, time.UTC),
+		},
+		{
+			Title:       "Content without summary",
+			URL:         "https://example.com/2",
+			Description: `<div xmlns="http://www.w3.org/1999/xhtml"><p>Inline</p></div>`,
+			GUID:        "urn:uuid:2",
+			// falls back to updated
+			PublishedAt: time.Date(2006, 1, 5, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			Title: "No id",
+			URL:   "https://example.com/3",
+			GUID:  "https://example.com/3",
+		},
+	})
+}
+
+func TestParseCharsets(t *testing.T) {
+	tests := []struct {
+		name    string
+		charset string
+		body    string
+		want    string
+	}{
+		{name: "latin-1", charset: "ISO-8859-1", body: "Caf\xe9 cr\xe8me", want: "Café crème"},
+		{name: "windows-1252 punctuation", charset: "windows-1252", body: "\x93quoted\x94 \x80 \x85", want: "“quoted” € …"},
+		{name: "windows-1252 letters", charset: "cp1252", body: "na\xefve", want: "naïve"},
+		{name: "ascii", charset: "us-ascii", body: "plain", want: "plain"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			feed := mustParse(t, `<?xml version="1.0" encoding="`+tt.charset+`"?>
+<rss version="2.0"><channel><title>`+tt.body+`</title><item><title>`+tt.body+`</title></item></channel></rss>`)
+			if feed.Title != tt.want {
+				t.Errorf("title = %q, want %q", feed.Title, tt.want)
+			}
+			if len(feed.Posts) != 1 || feed.Posts[0].Title != tt.want {
+				t.Errorf("posts = %+v, want one titled %q", feed.Posts, tt.want)
+			}
+		})
+	}
+
+	_, err := Parse(strings.NewReader(`<?xml version="1.0" encoding="koi8-r"?><rss><channel/></rss>`))
+	if err == nil || !strings.Contains(err.Error(), "unsupported charset") {
+		t.Errorf("Parse with an unsupported charset = %v", err)
+	}
+}
+
+func TestParseUnknownFormat(t *testing.T) {
+	for _, doc := range []string{
+		`<html><body>not a feed</body></html>`,
+		``,
+		`   `,
+	} {
+		if _, err := Parse(strings.NewReader(doc)); !errors.Is(err, ErrUnknownFormat) {
+			t.Errorf("Parse(%q) = %v, want ErrUnknownFormat", doc, err)
+		}
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Time
+	}{
+		// RFC1123Z and RFC1123
+		{"Mon, 02 Jan 2006 15:04:05 -0700", time.Date(2006, 1, 2, 22, 4, 5, 0, time.UTC)},
+		{"Mon, 02 Jan 2006 15:04:05 GMT", time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)},
+		// RFC3339 and RFC3339Nano
+		{"2006-01-02T15:04:05+07:00", time.Date(2006, 1, 2, 8, 4, 5, 0, time.UTC)},
+		{"2006-01-02T15:04:05.123456789Z", time.Date(2006, 1, 2, 15, 4, 5, 123456789, time.UTC)},
+		// RFC822Z and RFC822
+		{"02 Jan 06 15:04 -0700", time.Date(2006, 1, 2, 22, 4, 0, 0, time.UTC)},
+		{"02 Jan 06 15:04 UTC", time.Date(2006, 1, 2, 15, 4, 0, 0, time.UTC)},
+		// single digit days
+		{"Mon, 2 Jan 2006 15:04:05 -0700", time.Date(2006, 1, 2, 22, 4, 5, 0, time.UTC)},
+		{"Mon, 2 Jan 2006 15:04:05 GMT", time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)},
+		// no seconds
+		{"Mon, 02 Jan 2006 15:04 -0700", time.Date(2006, 1, 2, 22, 4, 0, 0, time.UTC)},
+		// no weekday
+		{"2 Jan 2006 15:04:05 -0700", time.Date(2006, 1, 2, 22, 4, 5, 0, time.UTC)},
+		{"02 Jan 2006 15:04:05 GMT", time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)},
+		// no zone, taken as UTC
+		{"2006-01-02T15:04:05", time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)},
+		{"2006-01-02 15:04:05", time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)},
+		{"2006-01-02", time.Date(2006, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{"  Mon, 02 Jan 2006 15:04:05 GMT\n", time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)},
+		{"", time.Time{}},
+		{"yesterday", time.Time{}},
+		{"2006-13-45", time.Time{}},
+	}
+	for _, tt := range tests {
+		got := parseDate(tt.in)
+		if !got.Equal(tt.want) {
+			t.Errorf("parseDate(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+		if !got.IsZero() && got.Location() != time.UTC {
+			t.Errorf("parseDate(%q) is in %v, want UTC", tt.in, got.Location())
+		}
+	}
+}
+
+func TestParseJSONFeed(t *testing.T) {
+	feed := mustParse(t, "\ufeff"+`  {
+	"version": "https://jsonfeed.org/version/1.1",
+	"title": "JSON example",
+	"home_page_url": "https://example.com/",
+	"description": "Items",
+	"items": [
+		{
+			"id": "1",
+			"url": "https://example.com/1",
+			"title": "HTML wins",
+			"content_html": "<p>html</p>",
+			"content_text": "text",
+			"summary": "summary",
+			"date_published": "2006-01-02T15:04:05-07:00",
+			"date_modified": "2006-01-05T00:00:00Z"
+		},
+		{
+			"id": 2,
+			"external_url": "https://elsewhere.example.com/2",
+			"title": "Numeric id and text content",
+			"content_text": "a < b",
+			"date_modified": "2006-01-05T00:00:00Z"
+		},
+		{
+			"url": "https://example.com/3",
+			"title": "Only a summary",
+			"summary": "Tom & Jerry"
+		}
+	]
+}`)
+
+	if feed.Title != "JSON example" || feed.Link != "https://example.com/" || feed.Description != "Items" {
+		t.Errorf("feed = %q %q %q", feed.Title, feed.Link, feed.Description)
+	}
+	wantPosts(t, feed.Posts, []Post{
+		{
+			Title:       "HTML wins",
+			URL:         "https://example.com/1",
+			Description: "<p>html</p>",
+			GUID:        "1",
+			PublishedAt: time.Date(2006, 1, 2, 22, 4, 5, 0, time.UTC),
+		},
+		{
+			Title:       "Numeric id and text content",
+			URL:         "https://elsewhere.example.com/2",
+			Description: "a &lt; b",
+			GUID:        "2",
+			PublishedAt: time.Date(2006, 1, 5, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			Title:       "Only a summary",
+			URL:         "https://example.com/3",
+			Description: "Tom &amp; Jerry",
+			GUID:        "https://example.com/3",
+		},
+	})
+}
+
+func TestParseJSONFeedErrors(t *testing.T) {
+	if _, err := Parse(strings.NewReader(`{"version": "1.1", "items": []}`)); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("JSON without a JSON Feed version = %v, want ErrUnknownFormat", err)
+	}
+	if _, err := Parse(strings.NewReader(`{"version": "https://jsonfeed.org/version/1.1", "items": [`)); err == nil || errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("truncated JSON Feed = %v, want a decoding error", err)
+	}
+	// 1.0 feeds are read the same way
+	feed := mustParse(t, `{"version": "https://jsonfeed.org/version/1", "items": [{"id": "a", "content_text": "x"}]}`)
+	if len(feed.Posts) != 1 || feed.Posts[0].GUID != "a" {
+		t.Errorf("1.0 feed posts = %+v", feed.Posts)
+	}
+}
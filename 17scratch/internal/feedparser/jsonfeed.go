@@ -0,0 +1,89 @@
+package feedparser
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"strings"
+)
+
+const jsonFeedVersionPrefix = "https://jsonfeed.org/version/"
+
+type jsonFeed struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	HomePageURL string         `json:"home_page_url"`
+	Description string         `json:"description"`
+	Items       []jsonFeedItem `json:"items"`
+}
+
+type jsonFeedItem struct {
+	// ID is a string in 1.1 but 1.0 feeds sometimes use numbers
+	ID            json.RawMessage `json:"id"`
+	URL           string          `json:"url"`
+	ExternalURL   string          `json:"external_url"`
+	Title         string          `json:"title"`
+	ContentHTML   string          `json:"content_html"`
+	ContentText   string          `json:"content_text"`
+	Summary       string          `json:"summary"`
+	DatePublished string          `json:"date_published"`
+	DateModified  string          `json:"date_modified"`
+}
+
+// idString returns the id whether it was sent as a string or a number
+func (item jsonFeedItem) idString() string {
+	var s string
+	if err := json.Unmarshal(item.ID, &s); err == nil {
+		return strings.TrimSpace(s)
+	}
+	var n json.Number
+	if err := json.Unmarshal(item.ID, &n); err == nil {
+		return n.String()
+	}
+	return ""
+}
+
+func parseJSONFeed(data []byte) (Feed, error) {
+	jf := jsonFeed{}
+	if err := json.Unmarshal(data, &jf); err != nil {
+		return Feed{}, fmt.Errorf("feedparser: json feed: %w", err)
+	}
+	if !strings.HasPrefix(jf.Version, jsonFeedVersionPrefix) {
+		return Feed{}, ErrUnknownFormat
+	}
+
+	feed := Feed{
+		Title:       strings.TrimSpace(jf.Title),
+		Link:        strings.TrimSpace(jf.HomePageURL),
+		Description: strings.TrimSpace(jf.Description),
+		Posts:       []Post{},
+	}
+	for _, item := range jf.Items {
+		post := Post{
+			Title:       strings.TrimSpace(item.Title),
+			URL:         strings.TrimSpace(item.URL),
+			Description: strings.TrimSpace(item.ContentHTML),
+			GUID:        item.idString(),
+			PublishedAt: parseDate(item.DatePublished),
+		}
+		if post.URL == "" {
+			post.URL = strings.TrimSpace(item.ExternalURL)
+		}
+		// content_text is plain text, escape it so it reads the same as the
+		// html descriptions of other formats
+		if post.Description == "" {
+			post.Description = html.EscapeString(strings.TrimSpace(item.ContentText))
+		}
+		if post.Description == "" {
+			post.Description = html.EscapeString(strings.TrimSpace(item.Summary))
+		}
+		if post.PublishedAt.IsZero() {
+			post.PublishedAt = parseDate(item.DateModified)
+		}
+		if post.GUID == "" {
+			post.GUID = post.URL
+		}
+		feed.Posts = append(feed.Posts, post)
+	}
+	return feed, nil
+}
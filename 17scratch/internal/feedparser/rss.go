@@ -0,0 +1,66 @@
+package feedparser
+
+import (
+	"fmt"
+	"strings"
+)
+
+type rssFeed struct {
+	Channel struct {
+		Title       string    `xml:"title"`
+		Link        string    `xml:"link"`
+		Description string    `xml:"description"`
+		Item        []rssItem `xml:"item"`
+	} `xml:"channel"`
+}
+
+type rssItem struct {
+	Title       string `xml:"title"`
+	Link        string `xml:"link"`
+	Description string `xml:"description"`
+	Encoded     string `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	PubDate     string `xml:"pubDate"`
+	Date        string `xml:"http://purl.org/dc/elements/1.1/ date"`
+	GUID        struct {
+		Value       string `xml:",chardata"`
+		IsPermaLink string `xml:"isPermaLink,attr"`
+	} `xml:"guid"`
+}
+
+func parseRSS(data []byte) (Feed, error) {
+	rss := rssFeed{}
+	if err := newDecoder(data).Decode(&rss); err != nil {
+		return Feed{}, fmt.Errorf("feedparser: rss: %w", err)
+	}
+
+	feed := Feed{
+		Title:       strings.TrimSpace(rss.Channel.Title),
+		Link:        strings.TrimSpace(rss.Channel.Link),
+		Description: strings.TrimSpace(rss.Channel.Description),
+		Posts:       []Post{},
+	}
+	for _, item := range rss.Channel.Item {
+		post := Post{
+			Title:       strings.TrimSpace(item.Title),
+			URL:         strings.TrimSpace(item.Link),
+			Description: strings.TrimSpace(item.Description),
+			GUID:        strings.TrimSpace(item.GUID.Value),
+			PublishedAt: parseDate(item.PubDate),
+		}
+		if post.Description == "" {
+			post.Description = strings.TrimSpace(item.Encoded)
+		}
+		if post.PublishedAt.IsZero() {
+			post.PublishedAt = parseDate(item.Date)
+		}
+		// a guid is a permalink unless it says otherwise
+		if post.URL == "" && post.GUID != "" && item.GUID.IsPermaLink != "false" {
+			post.URL = post.GUID
+		}
+		if post.GUID == "" {
+			post.GUID = post.URL
+		}
+		feed.Posts = append(feed.Posts, post)
+	}
+	return feed, nil
+}
@@ -0,0 +1,101 @@
+package opml
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// OPML is an OPML 2.0 subscription list. Readers nest feeds inside category
+// outlines, Feeds flattens them.
+type OPML struct {
+	XMLName xml.Name `xml:"opml"`
+	Version string   `xml:"version,attr"`
+	Head    Head     `xml:"head"`
+	Body    Body     `xml:"body"`
+}
+
+type Head struct {
+	Title       string `xml:"title,omitempty"`
+	DateCreated string `xml:"dateCreated,omitempty"`
+}
+
+type Body struct {
+	Outlines []Outline `xml:"outline"`
+}
+
+type Outline struct {
+	Text     string    `xml:"text,attr"`
+	Title    string    `xml:"title,attr,omitempty"`
+	Type     string    `xml:"type,attr,omitempty"`
+	XMLURL   string    `xml:"xmlUrl,attr,omitempty"`
+	HTMLURL  string    `xml:"htmlUrl,attr,omitempty"`
+	Outlines []Outline `xml:"outline"`
+}
+
+// Name is the best human readable name the outline has
+func (o Outline) Name() string {
+	if o.Title != "" {
+		return o.Title
+	}
+	if o.Text != "" {
+		return o.Text
+	}
+	return o.XMLURL
+}
+
+func Parse(r io.Reader) (OPML, error) {
+	doc := OPML{}
+	decoder := xml.NewDecoder(r)
+	decoder.Strict = false
+	decoder.Entity = xml.HTMLEntity
+	if err := decoder.Decode(&doc); err != nil {
+		return OPML{}, fmt.Errorf("opml: %w", err)
+	}
+	return doc, nil
+}
+
+// Feeds returns every outline with an xmlUrl, however deeply nested, in
+// document order
+func (doc OPML) Feeds() []Outline {
+	feeds := []Outline{}
+	var walk func(outlines []Outline)
+	walk = func(outlines []Outline) {
+		for _, o := range outlines {
+			if strings.TrimSpace(o.XMLURL) != "" {
+				o.XMLURL = strings.TrimSpace(o.XMLURL)
+				feeds = append(feeds, o)
+			}
+			walk(o.Outlines)
+		}
+	}
+	walk(doc.Body.Outlines)
+	return feeds
+}
+
+// New builds a flat subscription list out of feed outlines
+func New(title string, feeds []Outline) OPML {
+	return OPML{
+		Version: "2.0",
+		Head: Head{
+			Title:       title,
+			DateCreated: time.Now().UTC().Format(time.RFC1123Z),
+		},
+		Body: Body{Outlines: feeds},
+	}
+}
+
+func (doc OPML) Write(w io.Writer) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
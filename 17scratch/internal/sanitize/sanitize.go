@@ -0,0 +1,311 @@
+// Package sanitize makes the HTML of third party feeds safe to render. HTML
+// keeps only the elements and attributes on an allowlist, Text and Summary
+// reduce it to what a reader would see.
+package sanitize
+
+import (
+	"html"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	xhtml "golang.org/x/net/html"
+)
+
+// PolicyVersion identifies the rules below. Bump it whenever they change so
+// content sanitized under the old rules is sanitized again.
+const PolicyVersion = 1
+
+// allowedElements maps the elements that are kept to the attributes they
+// may carry. Any other element is dropped but its content kept.
+var allowedElements = map[string][]string{
+	"a":          {"href", "title"},
+	"abbr":       {"title"},
+	"b":          nil,
+	"blockquote": {"cite"},
+	"br":         nil,
+	"caption":    nil,
+	"cite":       nil,
+	"code":       nil,
+	"dd":         nil,
+	"del":        nil,
+	"div":        nil,
+	"dl":         nil,
+	"dt":         nil,
+	"em":         nil,
+	"figcaption": nil,
+	"figure":     nil,
+	"h1":         nil,
+	"h2":         nil,
+	"h3":         nil,
+	"h4":         nil,
+	"h5":         nil,
+	"h6":         nil,
+	"hr":         nil,
+	"i":          nil,
+	"img":        {"src", "alt", "title", "width", "height"},
+	"ins":        nil,
+	"kbd":        nil,
+	"li":         nil,
+	"mark":       nil,
+	"ol":         {"start"},
+	"p":          nil,
+	"pre":        nil,
+	"q":          {"cite"},
+	"s":          nil,
+	"small":      nil,
+	"span":       nil,
+	"strong":     nil,
+	"sub":        nil,
+	"sup":        nil,
+	"table":      nil,
+	"tbody":      nil,
+	"td":         {"colspan", "rowspan"},
+	"tfoot":      nil,
+	"th":         {"colspan", "rowspan"},
+	"thead":      nil,
+	"time":       {"datetime"},
+	"tr":         nil,
+	"u":          nil,
+	"ul":         nil,
+}
+
+// droppedElements are removed along with everything inside them
+var droppedElements = map[string]bool{
+	"script":   true,
+	"style":    true,
+	"iframe":   true,
+	"object":   true,
+	"embed":    true,
+	"noscript": true,
+	"template": true,
+	"svg":      true,
+	"math":     true,
+	"head":     true,
+	"title":    true,
+	"textarea": true,
+	"select":   true,
+	"frameset": true,
+	"noembed":  true,
+	"xmp":      true,
+}
+
+// voidElements have no end tag
+var voidElements = map[string]bool{
+	"br":  true,
+	"hr":  true,
+	"img": true,
+}
+
+// urlAttributes hold a url, which must be relative or use one of
+// allowedSchemes
+var urlAttributes = map[string]bool{
+	"href": true,
+	"src":  true,
+	"cite": true,
+}
+
+var allowedSchemes = map[string]bool{
+	"http":   true,
+	"https":  true,
+	"mailto": true,
+}
+
+// blockElements separate words in Text, "<p>a</p><p>b</p>" is two of them
+var blockElements = map[string]bool{
+	"address": true, "article": true, "aside": true, "blockquote": true,
+	"br": true, "caption": true, "dd": true, "div": true, "dl": true,
+	"dt": true, "figcaption": true, "figure": true, "footer": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"header": true, "hr": true, "img": true, "li": true, "main": true,
+	"nav": true, "ol": true, "p": true, "pre": true, "section": true,
+	"table": true, "td": true, "th": true, "tr": true, "ul": true,
+}
+
+// HTML returns s with everything off the allowlist removed: no scripts or
+// styles, no event handler or style attributes and no urls other than http,
+// https and mailto ones. Tags left open are closed and stray end tags
+// dropped, so the result can't break out of the element it's rendered in.
+func HTML(s string) string {
+	b := &strings.Builder{}
+	open := []string{}
+	skip := 0
+
+	z := xhtml.NewTokenizer(strings.NewReader(s))
+	for {
+		tt := z.Next()
+		if tt == xhtml.ErrorToken {
+			break
+		}
+		tok := z.Token()
+
+		switch tt {
+		case xhtml.StartTagToken, xhtml.SelfClosingTagToken:
+			if droppedElements[tok.Data] {
+				if tt == xhtml.StartTagToken {
+					skip++
+				}
+				continue
+			}
+			attrs, ok := allowedElements[tok.Data]
+			if skip > 0 {
+				continue
+			}
+			if !ok {
+				keepWordsApart(b, tok.Data)
+				continue
+			}
+			writeStartTag(b, tok, attrs)
+			if !voidElements[tok.Data] {
+				open = append(open, tok.Data)
+			}
+		case xhtml.EndTagToken:
+			if droppedElements[tok.Data] {
+				if skip > 0 {
+					skip--
+				}
+				continue
+			}
+			if skip > 0 {
+				continue
+			}
+			if _, ok := allowedElements[tok.Data]; !ok {
+				keepWordsApart(b, tok.Data)
+				continue
+			}
+			// close whatever was left open inside it, ignore it when it
+			// was never opened
+			for i := len(open) - 1; i >= 0; i-- {
+				if open[i] != tok.Data {
+					continue
+				}
+				for len(open) > i {
+					b.WriteString("</" + open[len(open)-1] + ">")
+					open = open[:len(open)-1]
+				}
+				break
+			}
+		case xhtml.TextToken:
+			if skip == 0 {
+				b.WriteString(html.EscapeString(tok.Data))
+			}
+		}
+	}
+	for i := len(open) - 1; i >= 0; i-- {
+		b.WriteString("</" + open[i] + ">")
+	}
+	return b.String()
+}
+
+// keepWordsApart stands in for a dropped block element, which would
+// otherwise run the words on either side of it together
+func keepWordsApart(b *strings.Builder, element string) {
+	if blockElements[element] {
+		b.WriteByte(' ')
+	}
+}
+
+func writeStartTag(b *strings.Builder, tok xhtml.Token, allowed []string) {
+	b.WriteString("<" + tok.Data)
+	seen := map[string]bool{}
+	for _, a := range tok.Attr {
+		if a.Namespace != "" || seen[a.Key] || !contains(allowed, a.Key) {
+			continue
+		}
+		val := a.Val
+		if urlAttributes[a.Key] {
+			var ok bool
+			if val, ok = safeURL(val); !ok {
+				continue
+			}
+		}
+		seen[a.Key] = true
+		b.WriteString(" " + a.Key + `="` + html.EscapeString(val) + `"`)
+	}
+	b.WriteString(">")
+}
+
+// safeURL reports whether a browser would only ever navigate to raw as a
+// relative url or one with an allowed scheme. Anything that doesn't parse is
+// refused, browsers are more forgiving than net/url.
+func safeURL(raw string) (string, bool) {
+	raw = strings.TrimSpace(raw)
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", false
+	}
+	if u.Scheme != "" && !allowedSchemes[strings.ToLower(u.Scheme)] {
+		return "", false
+	}
+	return raw, true
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Text reduces s to the text a reader would see: tags are dropped along with
+// the content of the ones HTML drops, entities decoded and whitespace
+// collapsed
+func Text(s string) string {
+	b := &strings.Builder{}
+	skip := 0
+
+	z := xhtml.NewTokenizer(strings.NewReader(s))
+	for {
+		tt := z.Next()
+		if tt == xhtml.ErrorToken {
+			break
+		}
+		tok := z.Token()
+
+		switch tt {
+		case xhtml.StartTagToken, xhtml.SelfClosingTagToken, xhtml.EndTagToken:
+			if droppedElements[tok.Data] && tt != xhtml.SelfClosingTagToken {
+				if tt == xhtml.StartTagToken {
+					skip++
+				} else if skip > 0 {
+					skip--
+				}
+			}
+			if blockElements[tok.Data] {
+				b.WriteByte(' ')
+			}
+		case xhtml.TextToken:
+			if skip == 0 {
+				b.WriteString(tok.Data)
+			}
+		}
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// Summary is the Text of s cut to at most max characters. It's cut at the
+// end of a word where there's one close enough, and ends with an ellipsis
+// when anything was cut.
+func Summary(s string, max int) string {
+	text := Text(s)
+	if utf8.RuneCountInString(text) <= max {
+		return text
+	}
+	if max < 1 {
+		return ""
+	}
+
+	// leave room for the ellipsis
+	cut := 0
+	for i := 0; i < max-1; i++ {
+		_, size := utf8.DecodeRuneInString(text[cut:])
+		cut += size
+	}
+	truncated := text[:cut]
+	if space := strings.LastIndexByte(truncated, ' '); space > len(truncated)/2 {
+		truncated = truncated[:space]
+	}
+	return strings.TrimRight(truncated, " ,.;:") + "…"
+}
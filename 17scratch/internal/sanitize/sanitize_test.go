@@ -0,0 +1,190 @@
+package sanitize
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestHTML(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "keeps allowed markup",
+			in:   `<p>Hello <b>bold</b> <a href="https://example.com/a?b=1&amp;c=2" title="t">link</a></p>`,
+			want: `<p>Hello <b>bold</b> <a href="https://example.com/a?b=1&amp;c=2" title="t">link</a></p>`,
+		},
+		{
+			name: "removes script with its content",
+			in:   `<p>a</p><script>alert(1)</script><p>b</p>`,
+			want: `<p>a</p><p>b</p>`,
+		},
+		{
+			name: "removes style with its content",
+			in:   `<style>p { color: red }</style><p>a</p>`,
+			want: `<p>a</p>`,
+		},
+		{
+			name: "removes script inside svg",
+			in:   `<svg><script>alert(1)</script><text>svg</text></svg>after`,
+			want: `after`,
+		},
+		{
+			name: "removes iframe with its content",
+			in:   `<iframe src="https://example.com">inside</iframe>tail`,
+			want: `tail`,
+		},
+		{
+			name: "uppercase script",
+			in:   `<SCRIPT>alert(1)</SCRIPT>ok`,
+			want: `ok`,
+		},
+		{
+			name: "strips event handlers",
+			in:   `<p onclick="steal()" ONMOUSEOVER="steal()">a</p><img src="https://example.com/i.png" onerror="steal()" alt="i">`,
+			want: `<p>a</p><img src="https://example.com/i.png" alt="i">`,
+		},
+		{
+			name: "strips style and unlisted attributes",
+			in:   `<div style="background:url(javascript:x)" class="c" id="i">a</div>`,
+			want: `<div>a</div>`,
+		},
+		{
+			name: "drops javascript urls",
+			in:   `<a href="javascript:alert(1)">x</a>`,
+			want: `<a>x</a>`,
+		},
+		{
+			name: "drops mixed case javascript urls",
+			in:   `<a href="JaVaScRiPt:alert(1)">x</a>`,
+			want: `<a>x</a>`,
+		},
+		{
+			name: "drops javascript urls behind whitespace",
+			in:   `<a href="  javascript:alert(1)">x</a><a href="&#x20;javascript:alert(1)">y</a>`,
+			want: `<a>x</a><a>y</a>`,
+		},
+		{
+			name: "drops javascript urls with control characters",
+			in:   `<a href="java&#x09;script:alert(1)">x</a><a href="java&#10;script:alert(1)">y</a><a href="java&#0;script:alert(1)">z</a>`,
+			want: `<a>x</a><a>y</a><a>z</a>`,
+		},
+		{
+			name: "drops entity encoded javascript urls",
+			in:   `<a href="&#106;&#97;&#118;&#97;&#115;&#99;&#114;&#105;&#112;&#116;&#58;alert(1)">x</a><a href="javascript&colon;alert(1)">y</a>`,
+			want: `<a>x</a><a>y</a>`,
+		},
+		{
+			name: "drops data and vbscript urls",
+			in:   `<img src="data:image/svg+xml;base64,PHN2Zz4=" alt="d"><a href="vbscript:msgbox(1)">v</a>`,
+			want: `<img alt="d"><a>v</a>`,
+		},
+		{
+			name: "keeps relative and mailto urls",
+			in:   `<a href="/posts/1">r</a><a href="mailto:a@example.com">m</a>`,
+			want: `<a href="/posts/1">r</a><a href="mailto:a@example.com">m</a>`,
+		},
+		{
+			name: "closes unclosed tags",
+			in:   `<div>unclosed <em>em`,
+			want: `<div>unclosed <em>em</em></div>`,
+		},
+		{
+			name: "drops stray end tags",
+			in:   `</div></div><p>a</p></span>`,
+			want: `<p>a</p>`,
+		},
+		{
+			name: "closes tags left open inside an element",
+			in:   `<p><b>bold</p>after`,
+			want: `<p><b>bold</b></p>after`,
+		},
+		{
+			name: "keeps words apart where block elements are dropped",
+			in:   `<section>a</section><section>b</section>`,
+			want: ` a  b `,
+		},
+		{
+			name: "escapes text",
+			in:   `a < b &amp; "c"`,
+			want: `a &lt; b &amp; &#34;c&#34;`,
+		},
+		{
+			name: "drops comments",
+			in:   `<!-- <script>alert(1)</script> --><p>x</p>`,
+			want: `<p>x</p>`,
+		},
+		{
+			name: "escapes attribute values",
+			in:   `<a title='x"><script>alert(1)</script>'>t</a>`,
+			want: `<a title="x&#34;&gt;&lt;script&gt;alert(1)&lt;/script&gt;">t</a>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := HTML(tt.in)
+			if got != tt.want {
+				t.Errorf("HTML(%q)\n got %q\nwant %q", tt.in, got, tt.want)
+			}
+			// stored content is sanitized again when the policy changes
+			if again := HTML(got); again != got {
+				t.Errorf("HTML isn't idempotent: %q became %q", got, again)
+			}
+		})
+	}
+}
+
+func TestText(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{`<p>Hello <b>world</b></p>`, `Hello world`},
+		{`<p>a</p><p>b</p>`, `a b`},
+		{`a<br>b`, `a b`},
+		{`<script>alert(1)</script>shown<style>p{}</style>`, `shown`},
+		{`caf&eacute; &amp; &lt;tag&gt;`, `café & <tag>`},
+		{"  lots \n\t of   space ", `lots of space`},
+	}
+	for _, tt := range tests {
+		if got := Text(tt.in); got != tt.want {
+			t.Errorf("Text(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSummary(t *testing.T) {
+	const long = "Lorem ipsum dolor sit amet, consectetur adipiscing elit"
+	tests := []struct {
+		name string
+		in   string
+		max  int
+		want string
+	}{
+		{name: "short text is kept whole", in: "<p>Short</p>", max: 10, want: "Short"},
+		{name: "exactly max", in: "12345", max: 5, want: "12345"},
+		{name: "cut at a word", in: long, max: 20, want: "Lorem ipsum dolor…"},
+		{name: "trailing punctuation dropped", in: long, max: 29, want: "Lorem ipsum dolor sit amet…"},
+		{name: "long word cut mid word", in: "Supercalifragilisticexpialidocious", max: 10, want: "Supercali…"},
+		{name: "counts characters not bytes", in: "ééééééééé", max: 5, want: "éééé…"},
+		{name: "markup doesn't count", in: "<p><b>ab</b></p>", max: 2, want: "ab"},
+		{name: "zero", in: long, max: 0, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Summary(tt.in, tt.max)
+			if got != tt.want {
+				t.Errorf("Summary(%q, %d) = %q, want %q", tt.in, tt.max, got, tt.want)
+			}
+			if n := utf8.RuneCountInString(got); n > tt.max {
+				t.Errorf("summary is %d characters, more than %d", n, tt.max)
+			}
+			if strings.Contains(got, "<") {
+				t.Errorf("summary has markup: %q", got)
+			}
+		})
+	}
+}
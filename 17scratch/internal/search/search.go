@@ -0,0 +1,361 @@
+// Package search is an in-memory inverted index over post titles and
+// descriptions. Queries match every word they contain, optionally as quoted
+// phrases, and results are ranked with BM25.
+package search
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anishakd4/rssagg/internal/sanitize"
+	"github.com/google/uuid"
+)
+
+const (
+	// maxQueryWords keeps a pasted paragraph from turning into a huge query
+	maxQueryWords = 32
+
+	// snippetSize is how many words a snippet holds and snippetLead how many
+	// of them come before the first match
+	snippetSize = 30
+	snippetLead = 5
+
+	// BM25 parameters, the usual defaults
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+var ErrEmptyQuery = errors.New("query has no words to search for")
+
+type field int
+
+const (
+	fieldTitle field = iota
+	fieldBody
+	numFields
+)
+
+// fieldWeights count a word in the title as if it appeared that many times
+var fieldWeights = [numFields]float64{3, 1}
+
+// Document is what gets indexed for a post
+type Document struct {
+	ID     uuid.UUID
+	FeedID uuid.UUID
+	Title  string
+	// Body may be HTML, only its text is indexed
+	Body        string
+	PublishedAt time.Time
+}
+
+type document struct {
+	feedID      uuid.UUID
+	publishedAt time.Time
+	text        [numFields]string
+	// length is the weighted number of words, BM25 favours shorter documents
+	length float64
+}
+
+// posting is where a term appears in one document, as word positions in each
+// field
+type posting [numFields][]int
+
+// Index is safe for concurrent use
+type Index struct {
+	docs        map[uuid.UUID]*document
+	postings    map[string]map[uuid.UUID]*posting
+	totalLength float64
+	mu          *sync.RWMutex
+}
+
+func NewIndex() *Index {
+	return &Index{
+		docs:     make(map[uuid.UUID]*document),
+		postings: make(map[string]map[uuid.UUID]*posting),
+		mu:       &sync.RWMutex{},
+	}
+}
+
+// Len is the number of documents indexed
+func (idx *Index) Len() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return len(idx.docs)
+}
+
+// Add indexes d, replacing what was indexed for a document with the same
+// ID. A stored post's description does change when it's sanitized again.
+func (idx *Index) Add(d Document) {
+	doc := &document{
+		feedID:      d.FeedID,
+		publishedAt: d.PublishedAt,
+		text:        [numFields]string{sanitize.Text(d.Title), sanitize.Text(d.Body)},
+	}
+	fieldTerms := [numFields][]string{}
+	for f := range doc.text {
+		fieldTerms[f] = terms(doc.text[f])
+		doc.length += fieldWeights[f] * float64(len(fieldTerms[f]))
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.remove(d.ID)
+	idx.docs[d.ID] = doc
+	idx.totalLength += doc.length
+	for f, terms := range fieldTerms {
+		for pos, term := range terms {
+			docs, ok := idx.postings[term]
+			if !ok {
+				docs = make(map[uuid.UUID]*posting)
+				idx.postings[term] = docs
+			}
+			p, ok := docs[d.ID]
+			if !ok {
+				p = &posting{}
+				docs[d.ID] = p
+			}
+			p[f] = append(p[f], pos)
+		}
+	}
+}
+
+// remove drops a document and its postings, if it's indexed. The caller
+// holds the write lock.
+func (idx *Index) remove(id uuid.UUID) {
+	doc, ok := idx.docs[id]
+	if !ok {
+		return
+	}
+	for _, text := range doc.text {
+		for _, term := range terms(text) {
+			docs := idx.postings[term]
+			delete(docs, id)
+			if len(docs) == 0 {
+				delete(idx.postings, term)
+			}
+		}
+	}
+	idx.totalLength -= doc.length
+	delete(idx.docs, id)
+}
+
+// Query is a parsed search. A document matches when it contains every one
+// of Terms and every one of Phrases as consecutive words, each in either its
+// title or its body.
+type Query struct {
+	Terms   []string
+	Phrases [][]string
+}
+
+// ParseQuery reads words and "quoted phrases". Words are split the same way
+// the text they're matched against is, so "e-mail" is the phrase "e mail".
+func ParseQuery(s string) (Query, error) {
+	q := Query{}
+	seen := map[string]bool{}
+	addWords := func(text string) {
+		words := terms(text)
+		switch {
+		case len(words) == 1 && !seen[words[0]]:
+			seen[words[0]] = true
+			q.Terms = append(q.Terms, words[0])
+		case len(words) > 1:
+			q.Phrases = append(q.Phrases, words)
+		}
+	}
+
+	for s != "" {
+		before, quoted, found := strings.Cut(s, `"`)
+		for _, word := range strings.Fields(before) {
+			addWords(word)
+		}
+		if !found {
+			break
+		}
+		// an unterminated quote runs to the end of the query
+		phrase, rest, _ := strings.Cut(quoted, `"`)
+		addWords(phrase)
+		s = rest
+	}
+
+	words := q.words()
+	if len(words) == 0 {
+		return Query{}, ErrEmptyQuery
+	}
+	if len(words) > maxQueryWords {
+		return Query{}, fmt.Errorf("query has more than %d distinct words", maxQueryWords)
+	}
+	return q, nil
+}
+
+// words is every distinct word in the query, from its terms and phrases
+func (q Query) words() []string {
+	seen := map[string]bool{}
+	words := []string{}
+	for _, term := range q.Terms {
+		if !seen[term] {
+			seen[term] = true
+			words = append(words, term)
+		}
+	}
+	for _, phrase := range q.Phrases {
+		for _, term := range phrase {
+			if !seen[term] {
+				seen[term] = true
+				words = append(words, term)
+			}
+		}
+	}
+	return words
+}
+
+type SearchParams struct {
+	Query Query
+	// Filter limits results to the feeds it returns true for, nil allows
+	// every feed
+	Filter func(feedID uuid.UUID) bool
+	Offset int
+	Limit  int
+}
+
+// Result is a matching document. Title and Snippet are HTML, escaped and
+// with the matched words wrapped in <mark>.
+type Result struct {
+	ID      uuid.UUID
+	Score   float64
+	Title   string
+	Snippet string
+}
+
+// Search returns the Limit best matches after skipping Offset of them. Ties
+// go to the newer post.
+func (idx *Index) Search(arg SearchParams) []Result {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	words := arg.Query.words()
+	wordSet := map[string]bool{}
+	// start from the rarest word so there are fewest candidates to check
+	var rarest map[uuid.UUID]*posting
+	for _, word := range words {
+		docs := idx.postings[word]
+		if len(docs) == 0 {
+			return []Result{}
+		}
+		if rarest == nil || len(docs) < len(rarest) {
+			rarest = docs
+		}
+		wordSet[word] = true
+	}
+
+	type match struct {
+		id    uuid.UUID
+		doc   *document
+		score float64
+	}
+	matches := []match{}
+	for id := range rarest {
+		doc := idx.docs[id]
+		if arg.Filter != nil && !arg.Filter(doc.feedID) {
+			continue
+		}
+		if !idx.matches(id, words, arg.Query.Phrases) {
+			continue
+		}
+		matches = append(matches, match{id: id, doc: doc, score: idx.score(id, doc, words)})
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if a.score != b.score {
+			return a.score > b.score
+		}
+		if !a.doc.publishedAt.Equal(b.doc.publishedAt) {
+			return a.doc.publishedAt.After(b.doc.publishedAt)
+		}
+		return a.id.String() < b.id.String()
+	})
+
+	if arg.Offset >= len(matches) {
+		return []Result{}
+	}
+	matches = matches[arg.Offset:]
+	if len(matches) > arg.Limit {
+		matches = matches[:arg.Limit]
+	}
+
+	results := make([]Result, 0, len(matches))
+	for _, m := range matches {
+		results = append(results, Result{
+			ID:      m.id,
+			Score:   m.score,
+			Title:   highlight(m.doc.text[fieldTitle], wordSet),
+			Snippet: snippet(m.doc.text[fieldBody], wordSet, snippetSize),
+		})
+	}
+	return results
+}
+
+func (idx *Index) matches(id uuid.UUID, words []string, phrases [][]string) bool {
+	for _, word := range words {
+		if _, ok := idx.postings[word][id]; !ok {
+			return false
+		}
+	}
+	for _, phrase := range phrases {
+		if !idx.hasPhrase(id, phrase) {
+			return false
+		}
+	}
+	return true
+}
+
+// hasPhrase looks for a position of the phrase's first word in a field that
+// every following word comes right after
+func (idx *Index) hasPhrase(id uuid.UUID, phrase []string) bool {
+	first := idx.postings[phrase[0]][id]
+	for f := field(0); f < numFields; f++ {
+	starts:
+		for _, start := range first[f] {
+			for i, word := range phrase[1:] {
+				positions := idx.postings[word][id][f]
+				want := start + i + 1
+				j := sort.SearchInts(positions, want)
+				if j == len(positions) || positions[j] != want {
+					continue starts
+				}
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// score is the BM25 score of a document, with words in the title weighted up
+func (idx *Index) score(id uuid.UUID, doc *document, words []string) float64 {
+	n := float64(len(idx.docs))
+	avgLength := idx.totalLength / n
+	if avgLength == 0 {
+		avgLength = 1
+	}
+
+	score := 0.0
+	for _, word := range words {
+		docs := idx.postings[word]
+		df := float64(len(docs))
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+
+		tf := 0.0
+		for f, positions := range docs[id] {
+			tf += fieldWeights[f] * float64(len(positions))
+		}
+		norm := bm25K1 * (1 - bm25B + bm25B*doc.length/avgLength)
+		score += idf * tf * (bm25K1 + 1) / (tf + norm)
+	}
+	return score
+}
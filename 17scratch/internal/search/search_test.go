@@ -0,0 +1,48 @@
+package search
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func search(t *testing.T, idx *Index, q string) []Result {
+	t.Helper()
+	query, err := ParseQuery(q)
+	if err != nil {
+		t.Fatalf("ParseQuery(%q): %v", q, err)
+	}
+	return idx.Search(SearchParams{Query: query, Limit: 10})
+}
+
+func TestAddReplacesDocument(t *testing.T) {
+	idx := NewIndex()
+	id := uuid.New()
+	other := Document{ID: uuid.New(), Title: "Other", Body: "shared words", PublishedAt: time.Now()}
+	idx.Add(other)
+	idx.Add(Document{ID: id, Title: "Post", Body: "old text with shared words", PublishedAt: time.Now()})
+	lengthBefore := idx.totalLength
+
+	idx.Add(Document{ID: id, Title: "Post", Body: "new text with shared words", PublishedAt: time.Now()})
+
+	if got := idx.Len(); got != 2 {
+		t.Errorf("Len = %d, want 2", got)
+	}
+	if got := search(t, idx, "old"); len(got) != 0 {
+		t.Errorf(`"old" still matches %v`, got)
+	}
+	if _, ok := idx.postings["old"]; ok {
+		t.Error(`the posting list of "old" wasn't dropped`)
+	}
+	got := search(t, idx, "new")
+	if len(got) != 1 || got[0].ID != id || got[0].Snippet != "<mark>new</mark> text with shared words" {
+		t.Errorf(`"new" = %+v, want the replaced post with its new snippet`, got)
+	}
+	if got := search(t, idx, "shared"); len(got) != 2 {
+		t.Errorf(`"shared" matches %d posts, want 2`, len(got))
+	}
+	if idx.totalLength != lengthBefore {
+		t.Errorf("totalLength = %v after replacing with a document as long, want %v", idx.totalLength, lengthBefore)
+	}
+}
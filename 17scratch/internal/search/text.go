@@ -0,0 +1,106 @@
+package search
+
+import (
+	"html"
+	"strings"
+	"unicode"
+)
+
+// token is a lowercased word of a text and where it sits in the original, as
+// byte offsets, so matches can be highlighted in place
+type token struct {
+	term       string
+	start, end int
+}
+
+// tokenize splits text into runs of letters and digits. There's no stemming,
+// "feeds" doesn't match "feed".
+func tokenize(text string) []token {
+	tokens := []token{}
+	start := -1
+	for i, r := range text {
+		isWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if isWord && start == -1 {
+			start = i
+		}
+		if !isWord && start != -1 {
+			tokens = append(tokens, token{term: strings.ToLower(text[start:i]), start: start, end: i})
+			start = -1
+		}
+	}
+	if start != -1 {
+		tokens = append(tokens, token{term: strings.ToLower(text[start:]), start: start, end: len(text)})
+	}
+	return tokens
+}
+
+func terms(text string) []string {
+	tokens := tokenize(text)
+	terms := make([]string, len(tokens))
+	for i, tok := range tokens {
+		terms[i] = tok.term
+	}
+	return terms
+}
+
+// markRange HTML escapes text[start:end], wrapping the tokens whose term is
+// in words in <mark>
+func markRange(text string, tokens []token, words map[string]bool, start, end int) string {
+	b := &strings.Builder{}
+	pos := start
+	for _, tok := range tokens {
+		if tok.start < start || tok.end > end || !words[tok.term] {
+			continue
+		}
+		b.WriteString(html.EscapeString(text[pos:tok.start]))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(text[tok.start:tok.end]))
+		b.WriteString("</mark>")
+		pos = tok.end
+	}
+	b.WriteString(html.EscapeString(text[pos:end]))
+	return b.String()
+}
+
+// highlight marks every match in text
+func highlight(text string, words map[string]bool) string {
+	return markRange(text, tokenize(text), words, 0, len(text))
+}
+
+// snippet cuts the window of size tokens holding the most matches out of
+// text, starting a little before the first of them, and highlights it.
+// Without any matches it's the start of the text.
+func snippet(text string, words map[string]bool, size int) string {
+	tokens := tokenize(text)
+	if len(tokens) <= size {
+		return highlight(text, words)
+	}
+
+	best, bestHits, hits := 0, 0, 0
+	for i, tok := range tokens {
+		if words[tok.term] {
+			hits++
+		}
+		if i >= size && words[tokens[i-size].term] {
+			hits--
+		}
+		if first := i - size + 1; first >= 0 && hits > bestHits {
+			best, bestHits = first, hits
+		}
+	}
+	for best > 0 && !words[tokens[best].term] {
+		best++
+	}
+	// a few words of context before the first match
+	best = max(0, min(best-snippetLead, len(tokens)-size))
+
+	last := best + size - 1
+	out := markRange(text, tokens, words, tokens[best].start, tokens[last].end)
+	if best > 0 {
+		out = "…" + out
+	}
+	if last < len(tokens)-1 {
+		out += "…"
+	}
+	return out
+}
@@ -2,32 +2,123 @@ package main
 
 import (
 	"encoding/json"
+	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
+	"strconv"
+	"strings"
 )
 
-func respondWithError(w http.ResponseWriter, code int,msg string){
-	if code > 499 {
-		log.Println("Responding with 5XX error")
-	}
+// errResponse is the body of every error response
+type errResponse struct {
+	Error string `json:"error"`
+}
 
-	type errResponse struct {
-		Error string `json:"error"`
+func respondWithError(w http.ResponseWriter, r *http.Request, code int,msg string){
+	if code > 499 {
+		slog.ErrorContext(r.Context(), "Responding with 5XX error",
+			"request_id", requestIDFromContext(r.Context()),
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", code,
+			"error", msg,
+		)
 	}
 
-	respondWithJSON(w, code, errResponse{
+	respondWithJSON(w, r, code, errResponse{
 		Error: msg,
 	})
 }
 
-func respondWithJSON(w http.ResponseWriter, code int, payload interface{}){
-	data, err := json.Marshal(payload)
-	if err!= nil {
-        log.Println("Failed to marshal JSON response: %v", err)
+// respondWithJSON compresses the body when the client accepts it and sets
+// Content-Length. ?pretty=1 indents it for humans.
+func respondWithJSON(w http.ResponseWriter, r *http.Request, code int, payload interface{}) {
+	data, err := marshalJSON(r, payload)
+	if err != nil {
+		log.Printf("Failed to marshal JSON response: %v", err)
 		w.WriteHeader(500)
-        return
-    }
-	w.Header().Add("Content-Type", "application/json")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	writeBody(w, r, code, data)
+}
+
+func marshalJSON(r *http.Request, payload interface{}) ([]byte, error) {
+	if !wantsPretty(r) {
+		return json.Marshal(payload)
+	}
+	data, err := json.MarshalIndent(payload, "", "  ")
+	return append(data, '\n'), err
+}
+
+func wantsPretty(r *http.Request) bool {
+	pretty, _ := strconv.ParseBool(r.URL.Query().Get("pretty"))
+	return pretty
+}
+
+// listResponse is the envelope every list endpoint responds with.
+// NextCursor is null on the last page.
+type listResponse struct {
+	Data       interface{} `json:"data"`
+	NextCursor *string     `json:"next_cursor"`
+}
+
+// respondWithList responds with the listResponse envelope, or streams one item
+// per line when the client asks for NDJSON. Either way the next page is also
+// linked in the Link header.
+func respondWithList[T any](w http.ResponseWriter, r *http.Request, code int, items []T, nextCursor string) {
+	if nextCursor != "" {
+		w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, nextPageURL(r, nextCursor)))
+	}
+	if acceptsNDJSON(r) {
+		streamNDJSON(w, r, code, items)
+		return
+	}
+
+	resp := listResponse{Data: items}
+	if nextCursor != "" {
+		resp.NextCursor = &nextCursor
+	}
+	respondWithJSON(w, r, code, resp)
+}
+
+func nextPageURL(r *http.Request, cursor string) string {
+	query := r.URL.Query()
+	query.Set("cursor", cursor)
+	u := *r.URL
+	u.RawQuery = query.Encode()
+	return u.RequestURI()
+}
+
+const ndjsonContentType = "application/x-ndjson"
+
+func acceptsNDJSON(r *http.Request) bool {
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, _ := strings.Cut(accept, ";")
+		switch strings.TrimSpace(mediaType) {
+		case ndjsonContentType, "application/ndjson":
+			return true
+		}
+	}
+	return false
+}
+
+// streamNDJSON encodes items one at a time straight into the (compressed)
+// response instead of marshalling the whole list first
+func streamNDJSON[T any](w http.ResponseWriter, r *http.Request, code int, items []T) {
+	w.Header().Set("Content-Type", ndjsonContentType)
+	body, closeBody := compressingWriter(w, r)
 	w.WriteHeader(code)
-	w.Write(data)
-}
\ No newline at end of file
+
+	encoder := json.NewEncoder(body)
+	for _, item := range items {
+		if err := encoder.Encode(item); err != nil {
+			log.Printf("Failed to stream NDJSON response: %v", err)
+			break
+		}
+	}
+	if err := closeBody(); err != nil {
+		log.Printf("Failed to finish NDJSON response: %v", err)
+	}
+}
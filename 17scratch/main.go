@@ -1,56 +1,265 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
+	"github.com/anishakd4/rssagg/internal/database"
+	"github.com/anishakd4/rssagg/internal/search"
 	"github.com/go-chi/chi"
 	"github.com/go-chi/cors"
-	"github.com/joho/godotenv"
 )
 
+type apiConfig struct {
+	DB     database.Store
+	Broker *postBroker
+	Search *search.Index
+	// FetchClient makes the requests to feeds outside the scraper
+	FetchClient *http.Client
+	// AllowPrivateNetworks lets webhooks point at internal hosts, see Config
+	AllowPrivateNetworks bool
+}
+
 func main()  {
+	cfg, err := loadConfig(os.Args[1:])
+	if cfg.PrintConfig {
+		cfg.print(os.Stdout)
+	}
+	if errors.Is(err, flag.ErrHelp) {
+		// -h or -help, the usage has been printed already
+		return
+	}
+	if err != nil {
+		log.Fatalf("Invalid config:\n%v", err)
+	}
+	if cfg.PrintConfig {
+		return
+	}
+
+	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stderr, nil)))
+
+	// not log.Fatal, which goes through slog by now
+	if err := runCommand(cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", cfg.Command, err)
+		os.Exit(1)
+	}
+}
+
+// serve runs the API along with the scraper and webhook dispatcher until it
+// gets SIGINT or SIGTERM
+func serve(cfg Config) error {
 	fmt.Println("Hello, world!")
 
-	godotenv.Load()
+	store, err := database.NewStore(context.Background(), cfg.DBDriver, cfg.DBURL)
+	if err != nil {
+		return fmt.Errorf("can't open database: %w", err)
+	}
+	defer store.Close()
 
-	// godotenv.Load(".env")
-	portString := os.Getenv("PORT")
-	
-	if portString == "" {
-        log.Fatal("could not find port")
-    }
+	broker := newPostBroker()
+	index := search.NewIndex()
+
+	apiCfg := apiConfig{
+		DB:                   store,
+		Broker:               broker,
+		Search:               index,
+		FetchClient:          newOutboundClient(fetchTimeout, cfg.AllowPrivateNetworks),
+		AllowPrivateNetworks: cfg.AllowPrivateNetworks,
+	}
+
+	// cancelled on SIGINT/SIGTERM, which starts the shutdown
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serverMetrics := newMetrics()
+
+	webhooks := newWebhookDispatcher(store, serverMetrics, cfg)
+
+	scraper := newScraper(store, serverMetrics, broker, webhooks, index, cfg)
+
+	indexer := newSearchIndexer(store, index)
+
+	sanitizer := newContentSanitizer(store, index, cfg)
+
+	readiness := newReadinessChecks()
+	readiness.register("storage", 2*time.Second, store.Ping)
+	readiness.register("scraper", time.Second, scraper.checkHeartbeat)
+	readiness.register("search", time.Second, indexer.checkBuilt)
+
+	workers := &sync.WaitGroup{}
+	workers.Add(3)
+	// sanitizing first means the index is built from the rewritten posts,
+	// rather than racing the rewrite and keeping whichever text came last
+	go func() {
+		defer workers.Done()
+		sanitizer.run(ctx)
+		indexer.build(ctx)
+	}()
+	go func() {
+		defer workers.Done()
+		scraper.start(ctx)
+	}()
+	go func() {
+		defer workers.Done()
+		webhooks.start(ctx)
+	}()
 
 	router := chi.NewRouter()
 
+	router.Use(middlewareRequestID)
+	router.Use(middlewareLogger)
+	router.Use(serverMetrics.middlewareMetrics)
+	router.Use(middlewareRecoverer)
+
 	router.Use(cors.Handler(cors.Options{
-		AllowedOrigins:   []string{"https://*", "http://*"},
+		AllowedOrigins:   cfg.AllowedOrigins,
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
 		AllowedHeaders:   []string{"*"},
-		ExposedHeaders:   []string{"Link"},
+		ExposedHeaders:   []string{"Link", requestIDHeader, "Retry-After", "X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset"},
 		AllowCredentials: false,
 		MaxAge:           300,
 	}))
 
-	v1Router := chi.NewRouter()
-	// v1Router.HandleFunc("/healthz", handler_readiness) this servers get and post both
-	v1Router.Get("/healthz", handler_readiness)
-	v1Router.Get("/err", handler_err)
+	limiters := newRateLimiters(cfg.RateLimits, store)
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		limiters.startSweeping(ctx, time.Minute)
+	}()
+
+	v1Router := newV1Router(&apiCfg, limiters, readiness)
 
 	router.Mount("/v1", v1Router)
+	router.Get("/metrics", serverMetrics.handler_metrics)
 
 	srv := &http.Server{
-		Handler: router,
-		Addr: ":"+portString,
+		Handler:           router,
+		Addr:              ":" + cfg.Port,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+	}
+	// Shutdown waits for connections to go idle, which a post stream never
+	// does on its own
+	srv.RegisterOnShutdown(broker.close)
+
+	fmt.Println("Port: ", cfg.Port)
+
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- srv.ListenAndServe()
+	}()
+
+	// a server that couldn't start, e.g. because the port is taken, still
+	// stops the workers and closes the store before its error is returned
+	var listenErr error
+	select {
+	case err := <-serverErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			listenErr = err
+		}
+	case <-ctx.Done():
+		log.Println("Shutting down...")
+	}
+	stop()
+
+	// stop accepting connections and let in flight requests finish
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Println("Couldn't shut down server cleanly:", err)
+	}
+
+	workersDone := make(chan struct{})
+	go func() {
+		workers.Wait()
+		close(workersDone)
+	}()
+	select {
+	case <-workersDone:
+	case <-shutdownCtx.Done():
+		log.Println("Timed out waiting for background workers")
+	}
+	if listenErr != nil {
+		return listenErr
 	}
+	log.Println("Server stopped")
+	return nil
+}
+
+// newV1Router routes the versioned API. Every route needs an entry in
+// apiRoutes so /v1/openapi.json stays complete, TestAPIRoutesDocumented
+// checks that.
+func newV1Router(apiCfg *apiConfig, limiters *rateLimiters, readiness *readinessChecks) *chi.Mux {
+	v1Router := chi.NewRouter()
+	// v1Router.HandleFunc("/livez", handler_livez) this servers get and post both
+	v1Router.Get("/livez", handler_livez)
+	v1Router.Get("/readyz", readiness.handler_readyz)
+	// kept for probes that were configured before livez/readyz existed
+	v1Router.Get("/healthz", handler_livez)
+	v1Router.Get("/err", handler_err)
+	v1Router.Get("/openapi.json", handler_openapi)
+
+	v1Router.Group(func(r chi.Router) {
+		r.Use(limiters.forGroup("users"))
+		r.Post("/users", apiCfg.handler_create_user)
+		r.Get("/users", apiCfg.middlewareAuth(apiCfg.handler_get_user))
+	})
+
+	v1Router.Group(func(r chi.Router) {
+		r.Use(limiters.forGroup("feeds"))
+		r.Post("/feeds", apiCfg.middlewareAuth(apiCfg.handler_create_feed))
+		r.Get("/feeds", apiCfg.handler_get_feeds)
+		r.Get("/feeds/{feedID}", apiCfg.handler_get_feed)
+
+		r.Post("/feed_follows", apiCfg.middlewareAuth(apiCfg.handler_create_feed_follow))
+		r.Get("/feed_follows", apiCfg.middlewareAuth(apiCfg.handler_get_feed_follows))
+		r.Delete("/feed_follows/{feedFollowID}", apiCfg.middlewareAuth(apiCfg.handler_delete_feed_follow))
+	})
+
+	v1Router.Group(func(r chi.Router) {
+		r.Use(limiters.forGroup("opml"))
+		r.Post("/opml", apiCfg.middlewareAuth(apiCfg.handler_import_opml))
+		r.Get("/opml", apiCfg.middlewareAuth(apiCfg.handler_export_opml))
+	})
+
+	v1Router.Group(func(r chi.Router) {
+		r.Use(limiters.forGroup("posts"))
+		r.Get("/posts", apiCfg.middlewareAuth(apiCfg.handler_get_posts_for_user))
+		r.Get("/posts/stream", apiCfg.middlewareAuth(apiCfg.handler_stream_posts))
+		r.Post("/posts/mark-read", apiCfg.middlewareAuth(apiCfg.handler_mark_posts_read))
+		r.Post("/posts/{postID}/read", apiCfg.middlewareAuth(apiCfg.handler_mark_post_read))
+		r.Post("/posts/{postID}/unread", apiCfg.middlewareAuth(apiCfg.handler_mark_post_unread))
+		r.Post("/posts/{postID}/star", apiCfg.middlewareAuth(apiCfg.handler_star_post))
+		r.Post("/posts/{postID}/unstar", apiCfg.middlewareAuth(apiCfg.handler_unstar_post))
+		r.Get("/users/{userID}/feed.json", apiCfg.handler_get_user_json_feed)
+		r.Get("/users/{userID}/feed.atom", apiCfg.handler_get_user_atom_feed)
+	})
 
-	fmt.Println("Port: ", portString)
+	v1Router.Group(func(r chi.Router) {
+		r.Use(limiters.forGroup("search"))
+		r.Get("/search", apiCfg.middlewareAuth(apiCfg.handler_search))
+	})
 
-	err := srv.ListenAndServe()
+	v1Router.Group(func(r chi.Router) {
+		r.Use(limiters.forGroup("webhooks"))
+		r.Post("/webhooks", apiCfg.middlewareAuth(apiCfg.handler_create_webhook))
+		r.Get("/webhooks", apiCfg.middlewareAuth(apiCfg.handler_get_webhooks))
+		r.Delete("/webhooks/{webhookID}", apiCfg.middlewareAuth(apiCfg.handler_delete_webhook))
+		r.Get("/webhooks/{webhookID}/dead_letters", apiCfg.middlewareAuth(apiCfg.handler_get_dead_letters))
+		r.Post("/webhooks/{webhookID}/dead_letters/{deliveryID}/retry", apiCfg.middlewareAuth(apiCfg.handler_retry_dead_letter))
+	})
 
-	if err!= nil {
-        log.Fatal(err)
-    }
-}
\ No newline at end of file
+	return v1Router
+}
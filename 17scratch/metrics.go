@@ -0,0 +1,212 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/middleware"
+)
+
+// latencyBuckets are the Prometheus client defaults, in seconds
+var latencyBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+type requestLabels struct {
+	method string
+	route  string
+	status int
+}
+
+type histogram struct {
+	// counts[i] is the number of observations <= latencyBuckets[i]
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func (h *histogram) observe(v float64) {
+	for i, bound := range latencyBuckets {
+		if v <= bound {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+// metrics collects what /metrics reports. It's written by hand in the
+// Prometheus text format rather than pulling in the client library.
+type metrics struct {
+	requests map[requestLabels]uint64
+	latency  map[requestLabels]*histogram
+
+	scraperFeedsFetched  uint64
+	scraperFetchFailures uint64
+	scraperPostsInserted uint64
+
+	webhookDeliveries  uint64
+	webhookFailures    uint64
+	webhookDeadLetters uint64
+
+	mu *sync.Mutex
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		requests: make(map[requestLabels]uint64),
+		latency:  make(map[requestLabels]*histogram),
+		mu:       &sync.Mutex{},
+	}
+}
+
+func (m *metrics) observeRequest(labels requestLabels, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.requests[labels]++
+	h, ok := m.latency[labels]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(latencyBuckets))}
+		m.latency[labels] = h
+	}
+	h.observe(d.Seconds())
+}
+
+func (m *metrics) feedFetched() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.scraperFeedsFetched++
+}
+
+func (m *metrics) feedFetchFailed() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.scraperFetchFailures++
+}
+
+func (m *metrics) postsInserted(n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.scraperPostsInserted += uint64(n)
+}
+
+func (m *metrics) webhookDelivered() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.webhookDeliveries++
+}
+
+// webhookFailed counts a failed attempt, dead is whether it was the last one
+func (m *metrics) webhookFailed(dead bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.webhookFailures++
+	if dead {
+		m.webhookDeadLetters++
+	}
+}
+
+// middlewareMetrics counts requests by route pattern rather than path so ids
+// in the url don't blow up the number of series
+func (m *metrics) middlewareMetrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+
+		status := ww.Status()
+		if status == 0 {
+			status = 200
+		}
+		route := routePattern(r)
+		if route == "" {
+			route = "unmatched"
+		}
+		m.observeRequest(requestLabels{
+			method: r.Method,
+			route:  route,
+			status: status,
+		}, time.Since(start))
+	})
+}
+
+func (m *metrics) handler_metrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.WriteHeader(200)
+	m.write(w)
+}
+
+func (m *metrics) write(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	labels := make([]requestLabels, 0, len(m.requests))
+	for l := range m.requests {
+		labels = append(labels, l)
+	}
+	// stable output makes the endpoint diffable
+	sort.Slice(labels, func(i, j int) bool {
+		if labels[i].route != labels[j].route {
+			return labels[i].route < labels[j].route
+		}
+		if labels[i].method != labels[j].method {
+			return labels[i].method < labels[j].method
+		}
+		return labels[i].status < labels[j].status
+	})
+
+	writeHeader(w, "rssagg_http_requests_total", "counter", "HTTP requests served.")
+	for _, l := range labels {
+		fmt.Fprintf(w, "rssagg_http_requests_total{%s} %d\n", l.String(), m.requests[l])
+	}
+
+	writeHeader(w, "rssagg_http_request_duration_seconds", "histogram", "HTTP request latency.")
+	for _, l := range labels {
+		h := m.latency[l]
+		for i, bound := range latencyBuckets {
+			fmt.Fprintf(w, "rssagg_http_request_duration_seconds_bucket{%s,le=\"%s\"} %d\n",
+				l.String(), formatFloat(bound), h.counts[i])
+		}
+		fmt.Fprintf(w, "rssagg_http_request_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", l.String(), h.count)
+		fmt.Fprintf(w, "rssagg_http_request_duration_seconds_sum{%s} %s\n", l.String(), formatFloat(h.sum))
+		fmt.Fprintf(w, "rssagg_http_request_duration_seconds_count{%s} %d\n", l.String(), h.count)
+	}
+
+	writeHeader(w, "rssagg_scraper_feeds_fetched_total", "counter", "Feeds fetched and parsed by the scraper.")
+	fmt.Fprintf(w, "rssagg_scraper_feeds_fetched_total %d\n", m.scraperFeedsFetched)
+	writeHeader(w, "rssagg_scraper_fetch_failures_total", "counter", "Feed fetches that failed.")
+	fmt.Fprintf(w, "rssagg_scraper_fetch_failures_total %d\n", m.scraperFetchFailures)
+	writeHeader(w, "rssagg_scraper_posts_inserted_total", "counter", "New posts stored by the scraper.")
+	fmt.Fprintf(w, "rssagg_scraper_posts_inserted_total %d\n", m.scraperPostsInserted)
+
+	writeHeader(w, "rssagg_webhook_deliveries_total", "counter", "Webhook payloads accepted by their endpoint.")
+	fmt.Fprintf(w, "rssagg_webhook_deliveries_total %d\n", m.webhookDeliveries)
+	writeHeader(w, "rssagg_webhook_failures_total", "counter", "Webhook delivery attempts that failed.")
+	fmt.Fprintf(w, "rssagg_webhook_failures_total %d\n", m.webhookFailures)
+	writeHeader(w, "rssagg_webhook_dead_letters_total", "counter", "Webhook deliveries given up on.")
+	fmt.Fprintf(w, "rssagg_webhook_dead_letters_total %d\n", m.webhookDeadLetters)
+}
+
+func writeHeader(w io.Writer, name, typ, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+func (l requestLabels) String() string {
+	return fmt.Sprintf("method=\"%s\",route=\"%s\",status=\"%d\"",
+		escapeLabelValue(l.method), escapeLabelValue(l.route), l.status)
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(v string) string {
+	return labelValueEscaper.Replace(v)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anishakd4/rssagg/internal/database"
+	"github.com/go-chi/chi"
+)
+
+// scrapeMetrics GETs /metrics from h and returns each sample by its name and
+// labels as written, e.g. `rssagg_http_requests_total{method="GET",...}`
+func scrapeMetrics(t *testing.T, h http.Handler) map[string]float64 {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if rec.Code != 200 {
+		t.Fatalf("GET /metrics = %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+
+	samples := map[string]float64{}
+	scanner := bufio.NewScanner(rec.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.LastIndexByte(line, ' ')
+		value, err := strconv.ParseFloat(line[i+1:], 64)
+		if err != nil {
+			t.Fatalf("bad sample %q: %v", line, err)
+		}
+		samples[line[:i]] = value
+	}
+	return samples
+}
+
+func wantSample(t *testing.T, samples map[string]float64, name string, want float64) {
+	t.Helper()
+	got, ok := samples[name]
+	if !ok {
+		t.Errorf("%s is missing", name)
+		return
+	}
+	if got != want {
+		t.Errorf("%s = %v, want %v", name, got, want)
+	}
+}
+
+func TestMetricsCountsRequests(t *testing.T) {
+	m := newMetrics()
+	router := chi.NewRouter()
+	router.Use(m.middlewareMetrics)
+	router.Get("/items/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(200)
+	})
+	router.Get("/slow", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(30 * time.Millisecond)
+		w.WriteHeader(201)
+	})
+	router.Get("/metrics", m.handler_metrics)
+
+	for _, path := range []string{"/items/1", "/items/2", "/items/3", "/slow", "/nowhere"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+	samples := scrapeMetrics(t, router)
+
+	// by route pattern, not path
+	wantSample(t, samples, `rssagg_http_requests_total{method="GET",route="/items/{id}",status="200"}`, 3)
+	wantSample(t, samples, `rssagg_http_requests_total{method="GET",route="/slow",status="201"}`, 1)
+	wantSample(t, samples, `rssagg_http_requests_total{method="GET",route="unmatched",status="404"}`, 1)
+
+	slow := `method="GET",route="/slow",status="201"`
+	wantSample(t, samples, `rssagg_http_request_duration_seconds_bucket{`+slow+`,le="0.025"}`, 0)
+	wantSample(t, samples, `rssagg_http_request_duration_seconds_bucket{`+slow+`,le="10"}`, 1)
+	wantSample(t, samples, `rssagg_http_request_duration_seconds_bucket{`+slow+`,le="+Inf"}`, 1)
+	wantSample(t, samples, `rssagg_http_request_duration_seconds_count{`+slow+`}`, 1)
+	if sum := samples[`rssagg_http_request_duration_seconds_sum{`+slow+`}`]; sum < 0.03 || sum > 10 {
+		t.Errorf("slow request duration sum = %v, want at least 0.03", sum)
+	}
+
+	items := `method="GET",route="/items/{id}",status="200"`
+	wantSample(t, samples, `rssagg_http_request_duration_seconds_count{`+items+`}`, 3)
+	wantSample(t, samples, `rssagg_http_request_duration_seconds_bucket{`+items+`,le="+Inf"}`, 3)
+	// buckets are cumulative
+	prev := 0.0
+	for _, bound := range latencyBuckets {
+		got := samples[`rssagg_http_request_duration_seconds_bucket{`+items+`,le="`+formatFloat(bound)+`"}`]
+		if got < prev {
+			t.Errorf("bucket le=%v = %v, less than the bucket before it (%v)", bound, got, prev)
+		}
+		prev = got
+	}
+	if prev != 3 {
+		t.Errorf("largest bucket = %v, want 3", prev)
+	}
+}
+
+func TestMetricsReportsScraper(t *testing.T) {
+	fixture := readFixture(t, "feed.xml")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/broken.xml" {
+			http.Error(w, "broken", http.StatusInternalServerError)
+			return
+		}
+		w.Write(fixture)
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	store := database.NewMemoryStore()
+	user := createTestUser(t, store)
+	good := createTestFeed(t, store, user, srv.URL+"/feed.xml")
+	broken := createTestFeed(t, store, user, srv.URL+"/broken.xml")
+
+	s := newTestScraper(t, store, testConfig())
+	s.scrapeFeed(ctx, good)
+	s.scrapeFeed(ctx, broken)
+
+	router := chi.NewRouter()
+	router.Get("/metrics", s.metrics.handler_metrics)
+	samples := scrapeMetrics(t, router)
+
+	wantSample(t, samples, "rssagg_scraper_feeds_fetched_total", 1)
+	wantSample(t, samples, "rssagg_scraper_fetch_failures_total", 1)
+	wantSample(t, samples, "rssagg_scraper_posts_inserted_total", 2)
+}
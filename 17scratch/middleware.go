@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+	"github.com/google/uuid"
+)
+
+const requestIDHeader = "X-Request-ID"
+
+type requestIDKey struct{}
+
+func requestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// validRequestID only lets through ids that are safe to echo back and to log
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, c := range id {
+		if c < 0x21 || c > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// middlewareRequestID reuses the caller's X-Request-ID so a request can be
+// followed across services, or makes a new one. Either way it's echoed in
+// the response.
+func middlewareRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID(id) {
+			id = uuid.NewString()
+		}
+		w.Header().Set(requestIDHeader, id)
+		ctx := context.WithValue(r.Context(), requestIDKey{}, id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// middlewareRecoverer turns a panicking handler into a 500 instead of a
+// dropped connection
+func middlewareRecoverer(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+			// the server uses this to abort a response on purpose
+			if rec == http.ErrAbortHandler {
+				panic(rec)
+			}
+			slog.ErrorContext(r.Context(), "Recovered from panic",
+				"request_id", requestIDFromContext(r.Context()),
+				"panic", fmt.Sprint(rec),
+				"stack", string(debug.Stack()),
+			)
+			respondWithError(w, r, 500, "Internal server error")
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// middlewareLogger writes one line per request once it has been served
+func middlewareLogger(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+
+		status := ww.Status()
+		// nothing was written, the server will send a 200
+		if status == 0 {
+			status = 200
+		}
+		level := slog.LevelInfo
+		if status > 499 {
+			level = slog.LevelError
+		}
+		slog.Log(r.Context(), level, "Served request",
+			"request_id", requestIDFromContext(r.Context()),
+			"method", r.Method,
+			"route", routePattern(r),
+			"path", r.URL.Path,
+			"status", status,
+			"bytes", ww.BytesWritten(),
+			"latency_ms", float64(time.Since(start).Microseconds())/1000,
+		)
+	})
+}
+
+// routePattern is the chi pattern that matched, e.g. /v1/feed_follows/{feedFollowID},
+// so requests for different ids group together. Empty when nothing matched.
+func routePattern(r *http.Request) string {
+	rctx := chi.RouteContext(r.Context())
+	if rctx == nil {
+		return ""
+	}
+	return rctx.RoutePattern()
+}
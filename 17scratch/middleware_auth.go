@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/anishakd4/rssagg/internal/auth"
+	"github.com/anishakd4/rssagg/internal/database"
+)
+
+type authedHandler func(http.ResponseWriter, *http.Request, database.User)
+
+// apiKeyUser is what looking up a request's api key found, kept in the
+// request context so the key is only looked up once per request
+type apiKeyUser struct {
+	apiKey string
+	user   database.User
+	err    error
+}
+
+type apiKeyUserKey struct{}
+
+// userForAPIKey looks up the user apiKey belongs to, reusing the lookup
+// already made for this request by the rate limiter. The returned request
+// carries the result for the handlers after it.
+func userForAPIKey(r *http.Request, db database.Store, apiKey string) (database.User, *http.Request, error) {
+	if found, ok := r.Context().Value(apiKeyUserKey{}).(apiKeyUser); ok && found.apiKey == apiKey {
+		return found.user, r, found.err
+	}
+	user, err := db.GetUserByAPIKey(r.Context(), apiKey)
+	ctx := context.WithValue(r.Context(), apiKeyUserKey{}, apiKeyUser{apiKey: apiKey, user: user, err: err})
+	return user, r.WithContext(ctx), err
+}
+
+func (apiCfg *apiConfig) middlewareAuth(handler authedHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		apiKey, err := auth.GetAPIKey(r.Header)
+		if err != nil {
+			respondWithError(w, r, 403, fmt.Sprintf("Auth error: %v", err))
+			return
+		}
+
+		user, r, err := userForAPIKey(r, apiCfg.DB, apiKey)
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, r, 403, "Couldn't find user")
+			return
+		}
+		if err != nil {
+			respondWithError(w, r, 500, fmt.Sprintf("Couldn't get user: %v", err))
+			return
+		}
+
+		handler(w, r, user)
+	}
+}
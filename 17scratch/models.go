@@ -0,0 +1,211 @@
+package main
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/anishakd4/rssagg/internal/database"
+	"github.com/google/uuid"
+)
+
+type User struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Name      string    `json:"name"`
+	ApiKey    string    `json:"api_key"`
+	// FeedToken is the token the user's timeline is published with, at
+	// /v1/users/{id}/feed.json?token={feed_token} and feed.atom
+	FeedToken string `json:"feed_token"`
+}
+
+func databaseUserToUser(dbUser database.User) User {
+	return User{
+		ID:        dbUser.ID,
+		CreatedAt: dbUser.CreatedAt,
+		UpdatedAt: dbUser.UpdatedAt,
+		Name:      dbUser.Name,
+		ApiKey:    dbUser.ApiKey,
+		FeedToken: dbUser.FeedToken,
+	}
+}
+
+type Feed struct {
+	ID            uuid.UUID  `json:"id"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	Name          string     `json:"name"`
+	Url           string     `json:"url"`
+	LastFetchedAt *time.Time `json:"last_fetched_at"`
+	FailureCount  int32      `json:"failure_count"`
+	LastError     string     `json:"last_error,omitempty"`
+	NextFetchAt   *time.Time `json:"next_fetch_at"`
+	Dead          bool       `json:"dead"`
+}
+
+func databaseFeedToFeed(dbFeed database.Feed) Feed {
+	return Feed{
+		ID:            dbFeed.ID,
+		CreatedAt:     dbFeed.CreatedAt,
+		UpdatedAt:     dbFeed.UpdatedAt,
+		Name:          dbFeed.Name,
+		Url:           dbFeed.Url,
+		LastFetchedAt: nullTimeToTimePtr(dbFeed.LastFetchedAt),
+		FailureCount:  dbFeed.FailureCount,
+		LastError:     dbFeed.LastError,
+		NextFetchAt:   nullTimeToTimePtr(dbFeed.NextFetchAt),
+		Dead:          dbFeed.DeadAt.Valid,
+	}
+}
+
+func databaseFeedsToFeeds(dbFeeds []database.Feed) []Feed {
+	feeds := []Feed{}
+	for _, dbFeed := range dbFeeds {
+		feeds = append(feeds, databaseFeedToFeed(dbFeed))
+	}
+	return feeds
+}
+
+type FeedFollow struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	UserID    uuid.UUID `json:"user_id"`
+	FeedID    uuid.UUID `json:"feed_id"`
+}
+
+func databaseFeedFollowToFeedFollow(dbFeedFollow database.FeedFollow) FeedFollow {
+	return FeedFollow{
+		ID:        dbFeedFollow.ID,
+		CreatedAt: dbFeedFollow.CreatedAt,
+		UpdatedAt: dbFeedFollow.UpdatedAt,
+		UserID:    dbFeedFollow.UserID,
+		FeedID:    dbFeedFollow.FeedID,
+	}
+}
+
+func databaseFeedFollowsToFeedFollows(dbFeedFollows []database.FeedFollow) []FeedFollow {
+	feedFollows := []FeedFollow{}
+	for _, dbFeedFollow := range dbFeedFollows {
+		feedFollows = append(feedFollows, databaseFeedFollowToFeedFollow(dbFeedFollow))
+	}
+	return feedFollows
+}
+
+func nullTimeToTimePtr(t sql.NullTime) *time.Time {
+	if t.Valid {
+		return &t.Time
+	}
+	return nil
+}
+
+type Post struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Title     string    `json:"title"`
+	Url       string    `json:"url"`
+	// Description is the feed's HTML, sanitized so it's safe to render, and
+	// Summary the start of its plain text, see SUMMARY_LENGTH
+	Description string    `json:"description"`
+	Summary     string    `json:"summary"`
+	PublishedAt time.Time `json:"published_at"`
+	Guid        string    `json:"guid"`
+	FeedID      uuid.UUID `json:"feed_id"`
+	// ReadAt and StarredAt are the requesting user's state of the post, null
+	// until it's read or starred
+	ReadAt    *time.Time `json:"read_at"`
+	StarredAt *time.Time `json:"starred_at"`
+}
+
+func databasePostToPost(dbPost database.Post) Post {
+	return Post{
+		ID:          dbPost.ID,
+		CreatedAt:   dbPost.CreatedAt,
+		UpdatedAt:   dbPost.UpdatedAt,
+		Title:       dbPost.Title,
+		Url:         dbPost.Url,
+		Description: dbPost.Description,
+		Summary:     dbPost.Summary,
+		PublishedAt: dbPost.PublishedAt,
+		Guid:        dbPost.Guid,
+		FeedID:      dbPost.FeedID,
+	}
+}
+
+func (post Post) withState(state database.PostState) Post {
+	post.ReadAt = nullTimeToTimePtr(state.ReadAt)
+	post.StarredAt = nullTimeToTimePtr(state.StarredAt)
+	return post
+}
+
+// SearchResult is a post matching a search. Title and Snippet are HTML
+// escaped with the matched words wrapped in <mark>.
+type SearchResult struct {
+	Post    Post    `json:"post"`
+	Score   float64 `json:"score"`
+	Title   string  `json:"title"`
+	Snippet string  `json:"snippet"`
+}
+
+// Webhook only carries its secret in the response that creates it
+type Webhook struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Url       string    `json:"url"`
+	Secret    string    `json:"secret,omitempty"`
+}
+
+func databaseWebhookToWebhook(dbWebhook database.Webhook) Webhook {
+	return Webhook{
+		ID:        dbWebhook.ID,
+		CreatedAt: dbWebhook.CreatedAt,
+		UpdatedAt: dbWebhook.UpdatedAt,
+		Url:       dbWebhook.Url,
+	}
+}
+
+func databaseWebhooksToWebhooks(dbWebhooks []database.Webhook) []Webhook {
+	webhooks := []Webhook{}
+	for _, dbWebhook := range dbWebhooks {
+		webhooks = append(webhooks, databaseWebhookToWebhook(dbWebhook))
+	}
+	return webhooks
+}
+
+type WebhookDelivery struct {
+	ID            uuid.UUID  `json:"id"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	WebhookID     uuid.UUID  `json:"webhook_id"`
+	PostID        uuid.UUID  `json:"post_id"`
+	Attempts      int32      `json:"attempts"`
+	LastError     string     `json:"last_error,omitempty"`
+	NextAttemptAt time.Time  `json:"next_attempt_at"`
+	DeliveredAt   *time.Time `json:"delivered_at"`
+	DeadAt        *time.Time `json:"dead_at"`
+}
+
+func databaseWebhookDeliveryToWebhookDelivery(dbDelivery database.WebhookDelivery) WebhookDelivery {
+	return WebhookDelivery{
+		ID:            dbDelivery.ID,
+		CreatedAt:     dbDelivery.CreatedAt,
+		UpdatedAt:     dbDelivery.UpdatedAt,
+		WebhookID:     dbDelivery.WebhookID,
+		PostID:        dbDelivery.PostID,
+		Attempts:      dbDelivery.Attempts,
+		LastError:     dbDelivery.LastError,
+		NextAttemptAt: dbDelivery.NextAttemptAt,
+		DeliveredAt:   nullTimeToTimePtr(dbDelivery.DeliveredAt),
+		DeadAt:        nullTimeToTimePtr(dbDelivery.DeadAt),
+	}
+}
+
+func databaseWebhookDeliveriesToWebhookDeliveries(dbDeliveries []database.WebhookDelivery) []WebhookDelivery {
+	deliveries := []WebhookDelivery{}
+	for _, dbDelivery := range dbDeliveries {
+		deliveries = append(deliveries, databaseWebhookDeliveryToWebhookDelivery(dbDelivery))
+	}
+	return deliveries
+}
@@ -0,0 +1,453 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/google/uuid"
+)
+
+// apiRoute documents one route mounted on v1Router. Request and Response are
+// zero values of the JSON bodies, their schemas are worked out from the json
+// tags. checkAPIRoutes makes sure this table and the router agree.
+type apiRoute struct {
+	Method  string
+	Path    string
+	Summary string
+	Auth    bool
+	Params  []apiParam
+
+	Request     interface{}
+	RequestType string
+
+	Status   int
+	Response interface{}
+	// List wraps Response, the item type, in the listResponse envelope
+	List         bool
+	ResponseType string
+}
+
+type apiParam struct {
+	Name        string
+	In          string
+	Description string
+}
+
+func listParamsDoc(sortField, defaultSort string) []apiParam {
+	return []apiParam{
+		{Name: "limit", In: "query", Description: fmt.Sprintf("page size, %d by default and at most %d", defaultListLimit, maxListLimit)},
+		{Name: "cursor", In: "query", Description: "next_cursor from the previous page"},
+		{Name: "sort", In: "query", Description: fmt.Sprintf("%s or -%s for descending, %s by default", sortField, sortField, defaultSort)},
+	}
+}
+
+var apiRoutes = []apiRoute{
+	{Method: "GET", Path: "/livez", Summary: "Report that the process is up",
+		Status: 200, Response: healthStatus{}},
+	{Method: "GET", Path: "/readyz", Summary: "Check the dependencies needed to serve traffic, 503 if any fail",
+		Status: 200, Response: readinessResponse{}},
+	{Method: "GET", Path: "/healthz", Summary: "Same as /livez, kept for older probes",
+		Status: 200, Response: healthStatus{}},
+	{Method: "GET", Path: "/err", Summary: "Always respond with an error",
+		Status: 400, Response: errResponse{}},
+	{Method: "GET", Path: "/openapi.json", Summary: "This document",
+		Status: 200, Response: map[string]interface{}{}},
+
+	{Method: "POST", Path: "/users", Summary: "Create a user and its api key",
+		Request: struct {
+			Name string `json:"name"`
+		}{},
+		Status: 201, Response: User{}},
+	{Method: "GET", Path: "/users", Summary: "Get the authenticated user", Auth: true,
+		Status: 200, Response: User{}},
+
+	{Method: "POST", Path: "/feeds", Summary: "Add a feed, or the feed a web page links to, and follow it", Auth: true,
+		Request: struct {
+			Name string `json:"name"`
+			URL  string `json:"url"`
+		}{},
+		Status: 201, Response: struct {
+			Feed       Feed       `json:"feed"`
+			FeedFollow FeedFollow `json:"feed_follow"`
+		}{}},
+	{Method: "GET", Path: "/feeds", Summary: "List every feed",
+		Params: append(listParamsDoc("created_at", "created_at"),
+			apiParam{Name: "q", In: "query", Description: "only feeds whose name contains this"},
+			apiParam{Name: "since", In: "query", Description: "only feeds added at or after this RFC3339 time"},
+		),
+		Status: 200, Response: Feed{}, List: true},
+	{Method: "GET", Path: "/feeds/{feedID}", Summary: "Get a feed",
+		Status: 200, Response: Feed{}},
+
+	{Method: "POST", Path: "/feed_follows", Summary: "Follow a feed", Auth: true,
+		Request: struct {
+			FeedID uuid.UUID `json:"feed_id"`
+		}{},
+		Status: 201, Response: FeedFollow{}},
+	{Method: "GET", Path: "/feed_follows", Summary: "List the feeds you follow", Auth: true,
+		Params: append(listParamsDoc("created_at", "created_at"),
+			apiParam{Name: "feed_id", In: "query", Description: "only follows of this feed"},
+			apiParam{Name: "since", In: "query", Description: "only follows made at or after this RFC3339 time"},
+		),
+		Status: 200, Response: FeedFollow{}, List: true},
+	{Method: "DELETE", Path: "/feed_follows/{feedFollowID}", Summary: "Unfollow a feed", Auth: true,
+		Status: 200, Response: struct{}{}},
+
+	{Method: "POST", Path: "/opml", Summary: "Import subscriptions from OPML", Auth: true,
+		RequestType: "text/x-opml",
+		Status:      200, Response: struct {
+			FeedsCreated     int `json:"feeds_created"`
+			FeedsFollowed    int `json:"feeds_followed"`
+			AlreadyFollowing int `json:"already_following"`
+			Failed           []struct {
+				URL   string `json:"url"`
+				Error string `json:"error"`
+			} `json:"failed"`
+		}{}},
+	{Method: "GET", Path: "/opml", Summary: "Export your subscriptions as OPML", Auth: true,
+		Status: 200, ResponseType: "text/x-opml"},
+
+	{Method: "GET", Path: "/posts", Summary: "List posts from the feeds you follow", Auth: true,
+		Params: append(listParamsDoc("published_at", "-published_at"),
+			apiParam{Name: "feed_id", In: "query", Description: "only posts from this feed"},
+			apiParam{Name: "since", In: "query", Description: "only posts published at or after this RFC3339 time"},
+			apiParam{Name: "before", In: "query", Description: "only posts published before this RFC3339 time"},
+			apiParam{Name: "q", In: "query", Description: "only posts whose title contains this"},
+			apiParam{Name: "state", In: "query", Description: "only unread, read or starred posts"},
+		),
+		Status: 200, Response: Post{}, List: true},
+	{Method: "GET", Path: "/posts/stream", Summary: "Stream new posts as Server-Sent Events", Auth: true,
+		Params: []apiParam{
+			{Name: "Last-Event-ID", In: "header", Description: "id of the last post received, to catch up after reconnecting"},
+		},
+		Status: 200, ResponseType: "text/event-stream"},
+	{Method: "POST", Path: "/posts/mark-read", Summary: "Mark every unread post from the feeds you follow read", Auth: true,
+		Params: []apiParam{
+			{Name: "feed_id", In: "query", Description: "only posts from this feed"},
+			{Name: "before", In: "query", Description: "only posts published before this RFC3339 time"},
+		},
+		Status: 200, Response: struct {
+			Marked int64 `json:"marked"`
+		}{}},
+	{Method: "POST", Path: "/posts/{postID}/read", Summary: "Mark a post read", Auth: true,
+		Status: 200, Response: Post{}},
+	{Method: "POST", Path: "/posts/{postID}/unread", Summary: "Mark a post unread", Auth: true,
+		Status: 200, Response: Post{}},
+	{Method: "POST", Path: "/posts/{postID}/star", Summary: "Star a post to save it for later", Auth: true,
+		Status: 200, Response: Post{}},
+	{Method: "POST", Path: "/posts/{postID}/unstar", Summary: "Unstar a post", Auth: true,
+		Status: 200, Response: Post{}},
+	{Method: "GET", Path: "/users/{userID}/feed.json", Summary: fmt.Sprintf("The newest %d posts of a user's timeline as a JSON Feed", userFeedSize),
+		Params: []apiParam{
+			{Name: "token", In: "query", Description: "the user's feed_token, required"},
+		},
+		Status: 200, ResponseType: "application/feed+json"},
+	{Method: "GET", Path: "/users/{userID}/feed.atom", Summary: fmt.Sprintf("The newest %d posts of a user's timeline as an Atom feed", userFeedSize),
+		Params: []apiParam{
+			{Name: "token", In: "query", Description: "the user's feed_token, required"},
+		},
+		Status: 200, ResponseType: "application/atom+xml"},
+
+	{Method: "GET", Path: "/search", Summary: "Search posts from the feeds you follow, best match first", Auth: true,
+		Params: []apiParam{
+			{Name: "q", In: "query", Description: `words that must all match, "quoted" for a phrase`},
+			{Name: "feed_id", In: "query", Description: "only posts from this feed"},
+			{Name: "limit", In: "query", Description: fmt.Sprintf("page size, %d by default and at most %d", defaultListLimit, maxListLimit)},
+			{Name: "cursor", In: "query", Description: "next_cursor from the previous page"},
+		},
+		Status: 200, Response: SearchResult{}, List: true},
+
+	{Method: "POST", Path: "/webhooks", Summary: "Register a webhook for new posts", Auth: true,
+		Request: struct {
+			URL string `json:"url"`
+		}{},
+		Status: 201, Response: Webhook{}},
+	{Method: "GET", Path: "/webhooks", Summary: "List your webhooks", Auth: true,
+		Params: listParamsDoc("created_at", "created_at"),
+		Status: 200, Response: Webhook{}, List: true},
+	{Method: "DELETE", Path: "/webhooks/{webhookID}", Summary: "Delete a webhook", Auth: true,
+		Status: 200, Response: struct{}{}},
+	{Method: "GET", Path: "/webhooks/{webhookID}/dead_letters", Summary: "List deliveries that were given up on", Auth: true,
+		Params: listParamsDoc("dead_at", "-dead_at"),
+		Status: 200, Response: WebhookDelivery{}, List: true},
+	{Method: "POST", Path: "/webhooks/{webhookID}/dead_letters/{deliveryID}/retry", Summary: "Queue a dead delivery again", Auth: true,
+		Status: 200, Response: WebhookDelivery{}},
+}
+
+// checkAPIRoutes reports routes on r that apiRoutes doesn't document, and
+// documented routes that don't exist
+func checkAPIRoutes(r chi.Routes) error {
+	documented := map[string]bool{}
+	for _, route := range apiRoutes {
+		documented[route.Method+" "+route.Path] = true
+	}
+
+	errs := []error{}
+	err := chi.Walk(r, func(method, route string, handler http.Handler, middlewares ...func(http.Handler) http.Handler) error {
+		key := method + " " + route
+		if !documented[key] {
+			errs = append(errs, fmt.Errorf("%s is not documented in apiRoutes", key))
+		}
+		delete(documented, key)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	stale := []string{}
+	for key := range documented {
+		stale = append(stale, key)
+	}
+	sort.Strings(stale)
+	for _, key := range stale {
+		errs = append(errs, fmt.Errorf("%s is documented in apiRoutes but not routed", key))
+	}
+	return errors.Join(errs...)
+}
+
+// openAPIDocument is built once, the route table doesn't change at runtime
+var openAPIDocument = sync.OnceValue(func() map[string]interface{} {
+	return buildOpenAPI(apiRoutes)
+})
+
+func handler_openapi(w http.ResponseWriter, r *http.Request) {
+	respondWithJSON(w, r, 200, openAPIDocument())
+}
+
+var pathParamPattern = regexp.MustCompile(`\{(\w+)\}`)
+
+func buildOpenAPI(routes []apiRoute) map[string]interface{} {
+	schemas := newSchemaBuilder()
+	errSchema := schemas.schemaFor(reflect.TypeOf(errResponse{}))
+
+	paths := map[string]map[string]interface{}{}
+	for _, route := range routes {
+		op := map[string]interface{}{
+			"summary":     route.Summary,
+			"operationId": operationID(route),
+		}
+
+		params := []interface{}{}
+		for _, match := range pathParamPattern.FindAllStringSubmatch(route.Path, -1) {
+			params = append(params, map[string]interface{}{
+				"name":     match[1],
+				"in":       "path",
+				"required": true,
+				"schema":   map[string]interface{}{"type": "string", "format": "uuid"},
+			})
+		}
+		for _, p := range route.Params {
+			params = append(params, map[string]interface{}{
+				"name":        p.Name,
+				"in":          p.In,
+				"description": p.Description,
+				"schema":      map[string]interface{}{"type": "string"},
+			})
+		}
+		if len(params) > 0 {
+			op["parameters"] = params
+		}
+
+		if route.Request != nil {
+			op["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{"schema": schemas.schemaFor(reflect.TypeOf(route.Request))},
+				},
+			}
+		} else if route.RequestType != "" {
+			op["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content": map[string]interface{}{
+					route.RequestType: map[string]interface{}{"schema": map[string]interface{}{"type": "string"}},
+				},
+			}
+		}
+
+		success := map[string]interface{}{"description": http.StatusText(route.Status)}
+		switch {
+		case route.ResponseType != "":
+			success["content"] = map[string]interface{}{
+				route.ResponseType: map[string]interface{}{"schema": map[string]interface{}{"type": "string"}},
+			}
+		case route.List:
+			// NDJSON is one item per line, without the envelope
+			success["content"] = map[string]interface{}{
+				"application/json": map[string]interface{}{"schema": schemas.listSchema(reflect.TypeOf(route.Response))},
+				ndjsonContentType:  map[string]interface{}{"schema": schemas.schemaFor(reflect.TypeOf(route.Response))},
+			}
+		case route.Response != nil:
+			success["content"] = map[string]interface{}{
+				"application/json": map[string]interface{}{"schema": schemas.schemaFor(reflect.TypeOf(route.Response))},
+			}
+		}
+		op["responses"] = map[string]interface{}{
+			fmt.Sprint(route.Status): success,
+			"default": map[string]interface{}{
+				"description": "Error",
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{"schema": errSchema},
+				},
+			},
+		}
+		if route.Auth {
+			op["security"] = []interface{}{map[string]interface{}{"apiKey": []string{}}}
+		}
+
+		if paths[route.Path] == nil {
+			paths[route.Path] = map[string]interface{}{}
+		}
+		paths[route.Path][strings.ToLower(route.Method)] = op
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "rssagg",
+			"version": "1.0.0",
+		},
+		"servers": []interface{}{map[string]interface{}{"url": "/v1"}},
+		"paths":   paths,
+		"components": map[string]interface{}{
+			"schemas": schemas.components,
+			"securitySchemes": map[string]interface{}{
+				"apiKey": map[string]interface{}{
+					"type":        "apiKey",
+					"in":          "header",
+					"name":        "Authorization",
+					"description": "ApiKey <key>",
+				},
+			},
+		},
+	}
+}
+
+// operationID turns "GET /feeds/{feedID}" into "getFeedsFeedID"
+func operationID(route apiRoute) string {
+	id := strings.ToLower(route.Method)
+	for _, part := range strings.FieldsFunc(route.Path, func(r rune) bool {
+		return !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9')
+	}) {
+		id += strings.ToUpper(part[:1]) + part[1:]
+	}
+	return id
+}
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+	uuidType = reflect.TypeOf(uuid.UUID{})
+)
+
+// schemaBuilder turns Go types into JSON schemas the way encoding/json would
+// marshal them. Named structs become components and are referenced.
+type schemaBuilder struct {
+	components map[string]interface{}
+}
+
+func newSchemaBuilder() *schemaBuilder {
+	return &schemaBuilder{components: map[string]interface{}{}}
+}
+
+func (b *schemaBuilder) listSchema(item reflect.Type) map[string]interface{} {
+	return map[string]interface{}{
+		"type":     "object",
+		"required": []string{"data", "next_cursor"},
+		"properties": map[string]interface{}{
+			"data": map[string]interface{}{
+				"type":  "array",
+				"items": b.schemaFor(item),
+			},
+			"next_cursor": map[string]interface{}{
+				"type":        "string",
+				"nullable":    true,
+				"description": "pass as cursor to get the next page, null on the last page",
+			},
+		},
+	}
+}
+
+func (b *schemaBuilder) schemaFor(t reflect.Type) map[string]interface{} {
+	switch t {
+	case timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case uuidType:
+		return map[string]interface{}{"type": "string", "format": "uuid"}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		schema := b.schemaFor(t.Elem())
+		// OpenAPI 3.0 ignores anything next to a $ref, so a nullable
+		// reference has to be wrapped
+		if _, ok := schema["$ref"]; ok {
+			return map[string]interface{}{"allOf": []interface{}{schema}, "nullable": true}
+		}
+		schema["nullable"] = true
+		return schema
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": b.schemaFor(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": b.schemaFor(t.Elem())}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.objectSchema(t)
+		}
+		if _, ok := b.components[t.Name()]; !ok {
+			// claim the name first in case the type refers to itself
+			b.components[t.Name()] = nil
+			b.components[t.Name()] = b.objectSchema(t)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + t.Name()}
+	}
+	// interface{} and anything else could be any JSON value
+	return map[string]interface{}{}
+}
+
+func (b *schemaBuilder) objectSchema(t reflect.Type) map[string]interface{} {
+	properties := map[string]interface{}{}
+	required := []string{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = b.schemaFor(field.Type)
+		if !strings.Contains(opts, "omitempty") {
+			required = append(required, name)
+		}
+	}
+
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anishakd4/rssagg/internal/database"
+	"github.com/anishakd4/rssagg/internal/search"
+	"github.com/go-chi/chi"
+)
+
+func newTestV1Router() *chi.Mux {
+	store := database.NewMemoryStore()
+	apiCfg := &apiConfig{
+		DB:     store,
+		Broker: newPostBroker(),
+		Search: search.NewIndex(),
+	}
+	limiters := newRateLimiters(map[string]rateLimit{
+		defaultRateLimitGroup: {Burst: 100, Per: time.Minute},
+	}, store)
+	return newV1Router(apiCfg, limiters, newReadinessChecks())
+}
+
+func TestAPIRoutesDocumented(t *testing.T) {
+	if err := checkAPIRoutes(newTestV1Router()); err != nil {
+		t.Errorf("v1 routes and apiRoutes disagree:\n%v", err)
+	}
+}
+
+func TestCheckAPIRoutesReportsUndocumentedRoute(t *testing.T) {
+	router := newTestV1Router()
+	router.Get("/undocumented", handler_livez)
+
+	err := checkAPIRoutes(router)
+	if err == nil || !strings.Contains(err.Error(), "GET /undocumented is not documented") {
+		t.Errorf("checkAPIRoutes = %v, want the undocumented route reported", err)
+	}
+}
+
+func TestSchemaForNullableReference(t *testing.T) {
+	type withPointers struct {
+		Webhook *Webhook   `json:"webhook"`
+		Seen    *time.Time `json:"seen"`
+	}
+	b := newSchemaBuilder()
+	schema := b.objectSchema(reflect.TypeOf(withPointers{}))
+	props := schema["properties"].(map[string]interface{})
+
+	webhook := props["webhook"].(map[string]interface{})
+	if _, ok := webhook["$ref"]; ok {
+		t.Errorf("nullable reference has $ref next to other keys: %v", webhook)
+	}
+	allOf, ok := webhook["allOf"].([]interface{})
+	if !ok || len(allOf) != 1 || webhook["nullable"] != true {
+		t.Fatalf("webhook schema = %v, want allOf with one $ref and nullable", webhook)
+	}
+	if ref := allOf[0].(map[string]interface{})["$ref"]; ref != "#/components/schemas/Webhook" {
+		t.Errorf("allOf[0].$ref = %v", ref)
+	}
+
+	seen := props["seen"].(map[string]interface{})
+	if seen["type"] != "string" || seen["nullable"] != true {
+		t.Errorf("seen schema = %v, want a nullable string", seen)
+	}
+}
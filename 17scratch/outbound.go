@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+var errBlockedAddress = errors.New("address is not publicly routable")
+
+// cgnatPrefix is shared address space carriers use in front of NAT, it's as
+// internal as the RFC 1918 ranges
+var cgnatPrefix = netip.MustParsePrefix("100.64.0.0/10")
+
+// newOutboundClient is the client for requests to urls users hand us, feeds
+// and webhooks. Unless allowPrivate is set it refuses to connect to loopback,
+// private and link-local addresses, so the server can't be used to reach
+// what's behind it. The check happens when connecting, after dns, so
+// redirects and names that resolve to internal addresses are caught too.
+func newOutboundClient(timeout time.Duration, allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   10 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	if !allowPrivate {
+		dialer.Control = refusePrivateAddress
+	}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:           dialer.DialContext,
+			ForceAttemptHTTP2:     true,
+			MaxIdleConns:          100,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ExpectContinueTimeout: time.Second,
+		},
+	}
+}
+
+func refusePrivateAddress(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil || !isPublicAddress(addrPort.Addr()) {
+		return fmt.Errorf("%s: %w", address, errBlockedAddress)
+	}
+	return nil
+}
+
+func isPublicAddress(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return !(addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsMulticast() ||
+		cgnatPrefix.Contains(addr))
+}
+
+// checkPublicURL rejects a url whose host resolves to an address
+// newOutboundClient would refuse, so users hear about it when they save the
+// url rather than when it's first used. The client still checks on every
+// connection, dns can change in between.
+func checkPublicURL(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	host := u.Hostname()
+	if addr, err := netip.ParseAddr(host); err == nil {
+		if !isPublicAddress(addr) {
+			return errBlockedAddress
+		}
+		return nil
+	}
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return fmt.Errorf("couldn't resolve %s", host)
+	}
+	for _, addr := range addrs {
+		if !isPublicAddress(addr) {
+			return errBlockedAddress
+		}
+	}
+	return nil
+}
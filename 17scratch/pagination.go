@@ -0,0 +1,140 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/anishakd4/rssagg/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultListLimit = 20
+	maxListLimit     = 100
+)
+
+// listParams are the paging and sorting parameters every list endpoint
+// takes: limit, cursor and sort. sort is a field name, prefixed with - for
+// descending order.
+type listParams struct {
+	limit int
+	after database.Cursor
+	sort  string
+	desc  bool
+}
+
+// cursorToken is what an opaque cursor decodes to. It remembers the sort it
+// was made for so it can't be used to page through a different order.
+type cursorToken struct {
+	Sort string    `json:"s"`
+	Time time.Time `json:"t"`
+	ID   uuid.UUID `json:"id"`
+}
+
+func encodeCursor(sort string, t time.Time, id uuid.UUID) string {
+	data, _ := json.Marshal(cursorToken{Sort: sort, Time: t, ID: id})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// parseListParams reads limit, cursor and sort from the query string. field
+// is the only field the endpoint sorts by, defaultSort is either field or
+// -field.
+func parseListParams(r *http.Request, field, defaultSort string) (listParams, error) {
+	query := r.URL.Query()
+	limit, err := parseLimit(r)
+	if err != nil {
+		return listParams{}, err
+	}
+	params := listParams{
+		limit: limit,
+		sort:  defaultSort,
+	}
+
+	if sort := query.Get("sort"); sort != "" {
+		if sort != field && sort != "-"+field {
+			return listParams{}, fmt.Errorf("sort must be %s or -%s", field, field)
+		}
+		params.sort = sort
+	}
+	params.desc = params.sort[0] == '-'
+
+	if cursor := query.Get("cursor"); cursor != "" {
+		token := cursorToken{}
+		data, err := base64.RawURLEncoding.DecodeString(cursor)
+		if err == nil {
+			err = json.Unmarshal(data, &token)
+		}
+		if err != nil || token.ID == uuid.Nil {
+			return listParams{}, errors.New("cursor is invalid")
+		}
+		if token.Sort != params.sort {
+			return listParams{}, fmt.Errorf("cursor was made for sort=%s", token.Sort)
+		}
+		params.after = database.Cursor{Time: token.Time, ID: token.ID, Valid: true}
+	}
+	return params, nil
+}
+
+// parseLimit reads the page size from the query string, capped at
+// maxListLimit
+func parseLimit(r *http.Request) (int, error) {
+	limitStr := r.URL.Query().Get("limit")
+	if limitStr == "" {
+		return defaultListLimit, nil
+	}
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil || limit < 1 {
+		return 0, errors.New("limit must be a positive integer")
+	}
+	return min(limit, maxListLimit), nil
+}
+
+// fetchLimit asks storage for one extra row, which tells us whether there is
+// another page without a separate count
+func (p listParams) fetchLimit() int32 {
+	return int32(p.limit + 1)
+}
+
+// page trims items fetched with fetchLimit down to the page and returns the
+// cursor of the next page, or "" on the last one. key returns the sort field
+// and id of an item.
+func page[T any](p listParams, items []T, key func(T) (time.Time, uuid.UUID)) ([]T, string) {
+	if len(items) <= p.limit {
+		return items, ""
+	}
+	items = items[:p.limit]
+	t, id := key(items[len(items)-1])
+	return items, encodeCursor(p.sort, t, id)
+}
+
+// parseUUIDQuery reads an optional uuid from the query string
+func parseUUIDQuery(r *http.Request, name string) (uuid.NullUUID, error) {
+	val := r.URL.Query().Get(name)
+	if val == "" {
+		return uuid.NullUUID{}, nil
+	}
+	id, err := uuid.Parse(val)
+	if err != nil {
+		return uuid.NullUUID{}, fmt.Errorf("%s must be a uuid: %v", name, err)
+	}
+	return uuid.NullUUID{UUID: id, Valid: true}, nil
+}
+
+// parseTimeQuery reads an optional RFC3339 timestamp from the query string
+func parseTimeQuery(r *http.Request, name string) (sql.NullTime, error) {
+	val := r.URL.Query().Get(name)
+	if val == "" {
+		return sql.NullTime{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, val)
+	if err != nil {
+		return sql.NullTime{}, fmt.Errorf("%s must be an RFC3339 timestamp: %v", name, err)
+	}
+	return sql.NullTime{Time: t.UTC(), Valid: true}, nil
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/anishakd4/rssagg/internal/database"
+	"github.com/anishakd4/rssagg/internal/sanitize"
+	"github.com/anishakd4/rssagg/internal/search"
+)
+
+// contentBatchSize is how many posts are read from storage at a time while
+// sanitizing them
+const contentBatchSize = 500
+
+// contentVersionSetting is the setting recording which sanitizer policy and
+// SUMMARY_LENGTH the stored posts are up to date with
+const contentVersionSetting = "post_content_version"
+
+// postContent is what's stored of a feed item's description: the HTML with
+// everything unsafe removed and a plain text summary of it
+func postContent(description string, summaryLength int) (string, string) {
+	return sanitize.HTML(description), sanitize.Summary(description, summaryLength)
+}
+
+// contentSanitizer brings the stored posts in line with the current
+// sanitizer policy and SUMMARY_LENGTH when the server starts. It only goes
+// through the posts when either changed since it last finished, and then
+// rewrites those whose content is out of date.
+type contentSanitizer struct {
+	db            database.Store
+	index         *search.Index
+	summaryLength int
+}
+
+func newContentSanitizer(db database.Store, index *search.Index, cfg Config) *contentSanitizer {
+	return &contentSanitizer{
+		db:            db,
+		index:         index,
+		summaryLength: cfg.SummaryLength,
+	}
+}
+
+// version is what the stored posts are sanitized against
+func (c *contentSanitizer) version() string {
+	return fmt.Sprintf("policy=%d summary_length=%d", sanitize.PolicyVersion, c.summaryLength)
+}
+
+// run rewrites every stored post whose content is out of date, unless they
+// were all brought up to date with this version already. Sanitizing is
+// idempotent so a post the scraper stores in the meantime is left as it is.
+func (c *contentSanitizer) run(ctx context.Context) {
+	version := c.version()
+	stored, err := c.db.GetSetting(ctx, contentVersionSetting)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.Println("Couldn't read the post content version:", err)
+		return
+	}
+	if stored == version {
+		return
+	}
+
+	start := time.Now()
+	updated := 0
+	failed := false
+	after := database.Cursor{}
+	for {
+		posts, err := c.db.GetPosts(ctx, database.GetPostsParams{
+			After: after,
+			Limit: contentBatchSize,
+		})
+		if err != nil {
+			log.Println("Couldn't sanitize posts:", err)
+			return
+		}
+		for _, post := range posts {
+			description, summary := postContent(post.Description, c.summaryLength)
+			if description == post.Description && summary == post.Summary {
+				continue
+			}
+			post, err := c.db.UpdatePostContent(ctx, database.UpdatePostContentParams{
+				ID:          post.ID,
+				Description: description,
+				Summary:     summary,
+				UpdatedAt:   time.Now().UTC(),
+			})
+			if err != nil {
+				log.Printf("Couldn't sanitize post %s: %v", post.ID, err)
+				failed = true
+				continue
+			}
+			// the search index has the old text and snippets otherwise
+			c.index.Add(postDocument(post))
+			updated++
+		}
+		if len(posts) < contentBatchSize {
+			break
+		}
+		last := posts[len(posts)-1]
+		after = database.Cursor{Time: last.CreatedAt, ID: last.ID, Valid: true}
+	}
+	if updated > 0 {
+		log.Printf("Sanitized %v posts in %s", updated, time.Since(start).Round(time.Millisecond))
+	}
+	// try again on the next start if a post couldn't be updated
+	if failed {
+		return
+	}
+	err = c.db.SetSetting(ctx, database.SetSettingParams{
+		Key:   contentVersionSetting,
+		Value: version,
+	})
+	if err != nil {
+		log.Println("Couldn't store the post content version:", err)
+	}
+}
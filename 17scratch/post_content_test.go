@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/anishakd4/rssagg/internal/database"
+	"github.com/anishakd4/rssagg/internal/search"
+	"github.com/google/uuid"
+)
+
+func TestContentSanitizerOnlyRunsWhenVersionChanges(t *testing.T) {
+	ctx := context.Background()
+	store := database.NewMemoryStore()
+	user := createTestUser(t, store)
+	feed := createTestFeed(t, store, user, "https://example.com/feed.xml")
+	now := time.Now().UTC()
+	// stored before descriptions were sanitized
+	post, err := store.CreatePost(ctx, database.CreatePostParams{
+		ID:          uuid.New(),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		Title:       "Old post",
+		Url:         "https://example.com/old",
+		Description: `<p onclick="steal()">Hello world</p><script>alert(1)</script>`,
+		PublishedAt: now,
+		Guid:        "old",
+		FeedID:      feed.ID,
+	})
+	if err != nil {
+		t.Fatalf("CreatePost: %v", err)
+	}
+	getPost := func() database.Post {
+		t.Helper()
+		p, err := store.GetPostByID(ctx, post.ID)
+		if err != nil {
+			t.Fatalf("GetPostByID: %v", err)
+		}
+		return p
+	}
+
+	cfg := testConfig()
+	newContentSanitizer(store, search.NewIndex(), cfg).run(ctx)
+	got := getPost()
+	if got.Description != `<p>Hello world</p>` || got.Summary != "Hello world" {
+		t.Fatalf("after the first run description = %q, summary = %q", got.Description, got.Summary)
+	}
+
+	// unsafe content that shows up now is only rewritten when the version
+	// changes, the same version doesn't go through the posts again
+	_, err = store.UpdatePostContent(ctx, database.UpdatePostContentParams{
+		ID:          post.ID,
+		Description: `<p onclick="steal()">Hello world</p>`,
+		Summary:     "Hello world",
+		UpdatedAt:   now,
+	})
+	if err != nil {
+		t.Fatalf("UpdatePostContent: %v", err)
+	}
+	newContentSanitizer(store, search.NewIndex(), cfg).run(ctx)
+	if got := getPost(); got.Description != `<p onclick="steal()">Hello world</p>` {
+		t.Errorf("rerun with the same version rewrote the post to %q", got.Description)
+	}
+
+	cfg.SummaryLength = 6
+	newContentSanitizer(store, search.NewIndex(), cfg).run(ctx)
+	got = getPost()
+	if got.Description != `<p>Hello world</p>` || got.Summary != "Hello…" {
+		t.Errorf("after SUMMARY_LENGTH changed description = %q, summary = %q", got.Description, got.Summary)
+	}
+}
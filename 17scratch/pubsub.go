@@ -0,0 +1,78 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/anishakd4/rssagg/internal/database"
+)
+
+// subscriberBuffer is how many posts a subscriber can fall behind by before
+// it gets dropped
+const subscriberBuffer = 64
+
+// postBroker fans newly scraped posts out to everyone listening. Publishing
+// never blocks the scraper, a subscriber that can't keep up has its channel
+// closed and is expected to reconnect and catch up from storage.
+type postBroker struct {
+	subscribers map[chan database.Post]struct{}
+	closed      bool
+	mu          *sync.Mutex
+}
+
+func newPostBroker() *postBroker {
+	return &postBroker{
+		subscribers: make(map[chan database.Post]struct{}),
+		mu:          &sync.Mutex{},
+	}
+}
+
+// subscribe returns a channel of new posts and a func to stop listening. The
+// channel is closed when the subscriber is dropped or the broker shuts down.
+func (b *postBroker) subscribe() (<-chan database.Post, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ch := make(chan database.Post, subscriberBuffer)
+	if b.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	b.subscribers[ch] = struct{}{}
+
+	unsubscribe := func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subscribers[ch]; ok {
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+	return ch, unsubscribe
+}
+
+func (b *postBroker) publish(post database.Post) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- post:
+		default:
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// close ends every subscription, it's called when the server shuts down so
+// long lived streams don't hold it up
+func (b *postBroker) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for ch := range b.subscribers {
+		delete(b.subscribers, ch)
+		close(ch)
+	}
+}
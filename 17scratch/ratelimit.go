@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anishakd4/rssagg/internal/auth"
+	"github.com/anishakd4/rssagg/internal/database"
+)
+
+const defaultRateLimitGroup = "default"
+
+// rateLimit allows Burst requests at once, refilled at Burst per Per
+type rateLimit struct {
+	Burst int
+	Per   time.Duration
+}
+
+func (l rateLimit) tokensPerSecond() float64 {
+	return float64(l.Burst) / l.Per.Seconds()
+}
+
+// parseRateLimits reads a list like "default=120/m,users=10/m". Units are
+// s, m or h.
+func parseRateLimits(val string) (map[string]rateLimit, error) {
+	limits := map[string]rateLimit{}
+	for _, item := range strings.Split(val, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		group, spec, ok := strings.Cut(item, "=")
+		if !ok || group == "" {
+			return nil, fmt.Errorf("%q should look like group=120/m", item)
+		}
+		countStr, unit, ok := strings.Cut(spec, "/")
+		if !ok {
+			return nil, fmt.Errorf("%q should look like group=120/m", item)
+		}
+		count, err := strconv.Atoi(countStr)
+		if err != nil || count < 1 {
+			return nil, fmt.Errorf("%q: request count must be a positive integer", item)
+		}
+		per := map[string]time.Duration{"s": time.Second, "m": time.Minute, "h": time.Hour}[unit]
+		if per == 0 {
+			return nil, fmt.Errorf("%q: unit must be s, m or h", item)
+		}
+		limits[group] = rateLimit{Burst: count, Per: per}
+	}
+	if _, ok := limits[defaultRateLimitGroup]; !ok {
+		return nil, fmt.Errorf("needs a %s limit", defaultRateLimitGroup)
+	}
+	return limits, nil
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// rateLimiter is a set of token buckets for one route group, keyed by user
+// or client ip
+type rateLimiter struct {
+	limit   rateLimit
+	db      database.Store
+	buckets map[string]*tokenBucket
+	mu      *sync.Mutex
+}
+
+func newRateLimiter(limit rateLimit, db database.Store) *rateLimiter {
+	return &rateLimiter{
+		limit:   limit,
+		db:      db,
+		buckets: make(map[string]*tokenBucket),
+		mu:      &sync.Mutex{},
+	}
+}
+
+// allow takes a token for key if there is one. It also reports the tokens
+// left and, when rejected, how long until the next token.
+func (rl *rateLimiter) allow(key string, now time.Time) (bool, int, time.Duration) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	rate := rl.limit.tokensPerSecond()
+	b, ok := rl.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: float64(rl.limit.Burst), last: now}
+		rl.buckets[key] = b
+	}
+	b.tokens = math.Min(float64(rl.limit.Burst), b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / rate * float64(time.Second))
+		return false, 0, wait
+	}
+	b.tokens--
+	return true, int(b.tokens), 0
+}
+
+// untilFull is how long an untouched bucket takes to refill completely
+func (rl *rateLimiter) untilFull(key string, now time.Time) time.Duration {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	b, ok := rl.buckets[key]
+	if !ok {
+		return 0
+	}
+	missing := float64(rl.limit.Burst) - b.tokens
+	return time.Duration(missing / rl.limit.tokensPerSecond() * float64(time.Second))
+}
+
+// sweep drops buckets that have been idle long enough to be full again,
+// they're no different from a bucket we'd create on the next request
+func (rl *rateLimiter) sweep(now time.Time) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	for key, b := range rl.buckets {
+		if now.Sub(b.last) >= rl.limit.Per {
+			delete(rl.buckets, key)
+		}
+	}
+}
+
+func (rl *rateLimiter) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key, r := rateLimitKey(r, rl.db)
+		now := time.Now()
+		allowed, remaining, retryAfter := rl.allow(key, now)
+
+		w.Header().Set("X-RateLimit-Limit", strconv.Itoa(rl.limit.Burst))
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+		w.Header().Set("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(rl.untilFull(key, now))))
+		if !allowed {
+			seconds := ceilSeconds(retryAfter)
+			w.Header().Set("Retry-After", strconv.Itoa(seconds))
+			respondWithError(w, r, 429, fmt.Sprintf("Rate limit exceeded, retry in %ds", seconds))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// rateLimitKey buckets requests by the user their api key belongs to, and
+// everyone else by the ip they connect from. A key that doesn't belong to a
+// user counts as no key, otherwise making up a new key for every request
+// would get a fresh bucket each time. The returned request remembers the
+// user so middlewareAuth doesn't look the key up again.
+func rateLimitKey(r *http.Request, db database.Store) (string, *http.Request) {
+	if apiKey, err := auth.GetAPIKey(r.Header); err == nil {
+		var user database.User
+		user, r, err = userForAPIKey(r, db, apiKey)
+		if err == nil {
+			return "user:" + user.ID.String(), r
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host, r
+}
+
+// rateLimiters hands out one limiter per route group so that, say, creating
+// users can be limited harder than reading posts
+type rateLimiters struct {
+	limits map[string]rateLimit
+	db     database.Store
+	groups map[string]*rateLimiter
+	mu     *sync.Mutex
+}
+
+func newRateLimiters(limits map[string]rateLimit, db database.Store) *rateLimiters {
+	return &rateLimiters{
+		limits: limits,
+		db:     db,
+		groups: make(map[string]*rateLimiter),
+		mu:     &sync.Mutex{},
+	}
+}
+
+// forGroup returns the middleware for group, which falls back to the default
+// limit when it hasn't been configured
+func (rls *rateLimiters) forGroup(group string) func(http.Handler) http.Handler {
+	rls.mu.Lock()
+	defer rls.mu.Unlock()
+
+	rl, ok := rls.groups[group]
+	if !ok {
+		limit, ok := rls.limits[group]
+		if !ok {
+			limit = rls.limits[defaultRateLimitGroup]
+		}
+		rl = newRateLimiter(limit, rls.db)
+		rls.groups[group] = rl
+	}
+	return rl.middleware
+}
+
+// startSweeping keeps the bucket maps from growing forever. It returns when
+// ctx is cancelled.
+func (rls *rateLimiters) startSweeping(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			rls.mu.Lock()
+			for _, rl := range rls.groups {
+				rl.sweep(now)
+			}
+			rls.mu.Unlock()
+		}
+	}
+}
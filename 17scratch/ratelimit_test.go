@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/anishakd4/rssagg/internal/database"
+	"github.com/anishakd4/rssagg/internal/search"
+)
+
+// countingStore counts api key lookups
+type countingStore struct {
+	database.Store
+	apiKeyLookups atomic.Int32
+}
+
+func (s *countingStore) GetUserByAPIKey(ctx context.Context, apiKey string) (database.User, error) {
+	s.apiKeyLookups.Add(1)
+	return s.Store.GetUserByAPIKey(ctx, apiKey)
+}
+
+func TestRateLimitedRequestLooksUpAPIKeyOnce(t *testing.T) {
+	store := &countingStore{Store: database.NewMemoryStore()}
+	user := createTestUser(t, store)
+	apiCfg := &apiConfig{DB: store, Broker: newPostBroker(), Search: search.NewIndex()}
+	limiters := newRateLimiters(map[string]rateLimit{
+		defaultRateLimitGroup: {Burst: 100, Per: time.Minute},
+	}, store)
+	router := newV1Router(apiCfg, limiters, newReadinessChecks())
+
+	tests := []struct {
+		apiKey string
+		want   int
+	}{
+		{user.ApiKey, 200},
+		{"made-up", 403},
+	}
+	for _, tt := range tests {
+		store.apiKeyLookups.Store(0)
+		req := httptest.NewRequest("GET", "/users", nil)
+		req.Header.Set("Authorization", "ApiKey "+tt.apiKey)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("GET /users with key %q = %d, want %d", tt.apiKey, rec.Code, tt.want)
+		}
+		if n := store.apiKeyLookups.Load(); n != 1 {
+			t.Errorf("GET /users with key %q looked the key up %d times, want 1", tt.apiKey, n)
+		}
+	}
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/anishakd4/rssagg/internal/database"
+	"github.com/anishakd4/rssagg/internal/feedparser"
+	"github.com/anishakd4/rssagg/internal/search"
+	"github.com/google/uuid"
+)
+
+// maxFeedSize caps how much of a response body we're willing to read
+const maxFeedSize = 10 << 20
+
+// fetchTimeout bounds a single request to a feed's server
+const fetchTimeout = 10 * time.Second
+
+type scraper struct {
+	db          database.Store
+	metrics     *metrics
+	broker      *postBroker
+	webhooks    *webhookDispatcher
+	index       *search.Index
+	client      *http.Client
+	batchSize   int
+	concurrency int
+	interval    time.Duration
+	maxFailures int
+	maxBackoff  time.Duration
+	// summaryLength caps the summary stored with each post
+	summaryLength int
+	// lastBeat is when the loop last started or finished a batch, as unix nanos
+	lastBeat *atomic.Int64
+}
+
+func newScraper(db database.Store, m *metrics, broker *postBroker, webhooks *webhookDispatcher, index *search.Index, cfg Config) *scraper {
+	return &scraper{
+		db:            db,
+		metrics:       m,
+		broker:        broker,
+		webhooks:      webhooks,
+		index:         index,
+		client:        newOutboundClient(fetchTimeout, cfg.AllowPrivateNetworks),
+		batchSize:     cfg.ScrapeBatchSize,
+		concurrency:   cfg.ScrapeConcurrency,
+		interval:      cfg.ScrapeInterval,
+		maxFailures:   cfg.ScrapeMaxFailures,
+		maxBackoff:    cfg.ScrapeMaxBackoff,
+		summaryLength: cfg.SummaryLength,
+		lastBeat:      &atomic.Int64{},
+	}
+}
+
+// start scrapes a batch of feeds straight away and then once every interval
+// until ctx is cancelled. In flight fetches are cancelled along with ctx.
+func (s *scraper) start(ctx context.Context) {
+	log.Printf("Scraping %v feeds every %s on %v goroutines", s.batchSize, s.interval, s.concurrency)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		s.beat()
+		s.scrapeBatch(ctx)
+		s.beat()
+
+		select {
+		case <-ctx.Done():
+			log.Println("Scraper stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *scraper) beat() {
+	s.lastBeat.Store(time.Now().UnixNano())
+}
+
+// checkHeartbeat fails when the loop hasn't moved for a few intervals, i.e.
+// it has stopped or a batch is stuck
+func (s *scraper) checkHeartbeat(ctx context.Context) error {
+	lastBeat := s.lastBeat.Load()
+	if lastBeat == 0 {
+		return errors.New("scraper hasn't started")
+	}
+	since := time.Since(time.Unix(0, lastBeat))
+	if since > 3*s.interval {
+		return fmt.Errorf("scraper last made progress %s ago", since.Round(time.Second))
+	}
+	return nil
+}
+
+// scrapeBatch fetches the least recently fetched feeds. The semaphore channel
+// keeps at most s.concurrency fetches in flight and the WaitGroup lets us wait
+// for the whole batch before the next tick.
+func (s *scraper) scrapeBatch(ctx context.Context) {
+	feeds, err := s.db.GetNextFeedsToFetch(ctx, database.GetNextFeedsToFetchParams{
+		Now:   time.Now().UTC(),
+		Limit: int32(s.batchSize),
+	})
+	if err != nil {
+		log.Println("Couldn't get next feeds to fetch:", err)
+		return
+	}
+
+	sem := make(chan struct{}, s.concurrency)
+	wg := &sync.WaitGroup{}
+	for _, feed := range feeds {
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(feed database.Feed) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			s.scrapeFeed(ctx, feed)
+		}(feed)
+	}
+	wg.Wait()
+}
+
+// scrapeFeed fetches feed and stores the posts it hasn't seen before,
+// returning how many that was. Failures are recorded on the feed as well as
+// returned.
+func (s *scraper) scrapeFeed(ctx context.Context, feed database.Feed) (int, error) {
+	// mark it first so a feed that keeps failing doesn't starve the others
+	if _, err := s.db.MarkFeedFetched(ctx, feed.ID); err != nil {
+		log.Printf("Couldn't mark feed %s fetched: %v", feed.Name, err)
+		return 0, err
+	}
+
+	result, err := s.fetchFeed(ctx, feed)
+	if err != nil {
+		// shutting down, that's no fault of the feed's
+		if ctx.Err() != nil {
+			return 0, err
+		}
+		s.recordFailure(ctx, feed, err)
+		return 0, err
+	}
+	if result.notModified {
+		s.recordSuccess(ctx, feed, feed.Etag, feed.LastModified)
+		log.Printf("Feed %s not modified", feed.Name)
+		return 0, nil
+	}
+
+	parsed, err := feedparser.Parse(bytes.NewReader(result.body))
+	if err != nil {
+		err = fmt.Errorf("parse: %w", err)
+		s.recordFailure(ctx, feed, err)
+		return 0, err
+	}
+	s.recordSuccess(ctx, feed, result.etag, result.lastModified)
+
+	inserted := 0
+	for _, item := range parsed.Posts {
+		// nothing to dedupe or link to
+		if item.GUID == "" && item.URL == "" {
+			continue
+		}
+
+		now := time.Now().UTC()
+		publishedAt := item.PublishedAt
+		if publishedAt.IsZero() {
+			publishedAt = now
+		}
+		// the description is third party HTML, only a sanitized copy is kept
+		description, summary := postContent(item.Description, s.summaryLength)
+		post, err := s.db.CreatePost(ctx, database.CreatePostParams{
+			ID:          uuid.New(),
+			CreatedAt:   now,
+			UpdatedAt:   now,
+			Title:       item.Title,
+			Url:         item.URL,
+			Description: description,
+			PublishedAt: publishedAt,
+			Guid:        item.GUID,
+			FeedID:      feed.ID,
+			Summary:     summary,
+		})
+		if database.IsUniqueViolation(err) {
+			continue
+		}
+		if err != nil {
+			log.Printf("Couldn't create post %q for feed %s: %v", item.Title, feed.Name, err)
+			continue
+		}
+		inserted++
+		s.index.Add(postDocument(post))
+		s.broker.publish(post)
+		s.webhooks.enqueue(ctx, post)
+	}
+	s.metrics.postsInserted(inserted)
+	log.Printf("Feed %s collected, %v posts found, %v new", feed.Name, len(parsed.Posts), inserted)
+	return inserted, nil
+}
+
+type fetchResult struct {
+	body         []byte
+	etag         string
+	lastModified string
+	// notModified means the server answered 304 to our validators
+	notModified bool
+}
+
+// fetchFeed makes the request conditional when we have validators from the
+// last successful fetch
+func (s *scraper) fetchFeed(ctx context.Context, feed database.Feed) (fetchResult, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, feed.Url, nil)
+	if err != nil {
+		return fetchResult{}, err
+	}
+	req.Header.Set("User-Agent", "rssagg")
+	if feed.Etag != "" {
+		req.Header.Set("If-None-Match", feed.Etag)
+	}
+	if feed.LastModified != "" {
+		req.Header.Set("If-Modified-Since", feed.LastModified)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fetchResult{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return fetchResult{notModified: true}, nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fetchResult{}, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxFeedSize))
+	if err != nil {
+		return fetchResult{}, err
+	}
+	return fetchResult{
+		body:         body,
+		etag:         resp.Header.Get("ETag"),
+		lastModified: resp.Header.Get("Last-Modified"),
+	}, nil
+}
+
+func (s *scraper) recordSuccess(ctx context.Context, feed database.Feed, etag, lastModified string) {
+	s.metrics.feedFetched()
+	_, err := s.db.MarkFeedFetchSucceeded(ctx, database.MarkFeedFetchSucceededParams{
+		ID:           feed.ID,
+		Etag:         etag,
+		LastModified: lastModified,
+	})
+	if err != nil {
+		log.Printf("Couldn't record fetch of feed %s: %v", feed.Name, err)
+	}
+}
+
+// recordFailure backs the feed off exponentially, starting at one scrape
+// interval, and gives up on it after maxFailures failures in a row
+func (s *scraper) recordFailure(ctx context.Context, feed database.Feed, fetchErr error) {
+	s.metrics.feedFetchFailed()
+
+	failures := int(feed.FailureCount) + 1
+	now := time.Now().UTC()
+	arg := database.MarkFeedFetchFailedParams{
+		ID:          feed.ID,
+		LastError:   fetchErr.Error(),
+		NextFetchAt: sql.NullTime{Time: now.Add(s.backoff(failures)), Valid: true},
+	}
+	if failures >= s.maxFailures {
+		arg.DeadAt = sql.NullTime{Time: now, Valid: true}
+		log.Printf("Feed %s failed %v times in a row, marking it dead: %v", feed.Name, failures, fetchErr)
+	} else {
+		log.Printf("Couldn't fetch feed %s, retrying in %s: %v", feed.Name, s.backoff(failures), fetchErr)
+	}
+
+	if _, err := s.db.MarkFeedFetchFailed(ctx, arg); err != nil {
+		log.Printf("Couldn't record failed fetch of feed %s: %v", feed.Name, err)
+	}
+}
+
+func (s *scraper) backoff(failures int) time.Duration {
+	d := s.interval
+	for i := 1; i < failures; i++ {
+		d *= 2
+		if d >= s.maxBackoff {
+			return s.maxBackoff
+		}
+	}
+	return min(d, s.maxBackoff)
+}
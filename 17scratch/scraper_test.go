@@ -0,0 +1,265 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/anishakd4/rssagg/internal/database"
+	"github.com/anishakd4/rssagg/internal/search"
+	"github.com/google/uuid"
+)
+
+// testConfig is the config the tests run with. The fixture servers listen
+// on loopback, so private networks are allowed.
+func testConfig() Config {
+	return Config{
+		ScrapeInterval:       time.Minute,
+		ScrapeBatchSize:      10,
+		ScrapeConcurrency:    3,
+		ScrapeMaxFailures:    10,
+		ScrapeMaxBackoff:     time.Hour,
+		SummaryLength:        300,
+		WebhookInterval:      time.Minute,
+		WebhookTimeout:       time.Second,
+		WebhookMaxAttempts:   3,
+		WebhookMaxBackoff:    time.Hour,
+		AllowPrivateNetworks: true,
+	}
+}
+
+func newTestScraper(t *testing.T, store database.Store, cfg Config) *scraper {
+	t.Helper()
+	m := newMetrics()
+	return newScraper(store, m, newPostBroker(), newWebhookDispatcher(store, m, cfg), search.NewIndex(), cfg)
+}
+
+func createTestUser(t *testing.T, store database.Store) database.User {
+	t.Helper()
+	now := time.Now().UTC()
+	user, err := store.CreateUser(context.Background(), database.CreateUserParams{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		Name:      "test",
+		ApiKey:    uuid.NewString(),
+		FeedToken: uuid.NewString(),
+	})
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	return user
+}
+
+func createTestFeed(t *testing.T, store database.Store, user database.User, url string) database.Feed {
+	t.Helper()
+	now := time.Now().UTC()
+	feed, err := store.CreateFeed(context.Background(), database.CreateFeedParams{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		Name:      url,
+		Url:       url,
+		UserID:    user.ID,
+	})
+	if err != nil {
+		t.Fatalf("CreateFeed: %v", err)
+	}
+	return feed
+}
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatalf("reading fixture: %v", err)
+	}
+	return data
+}
+
+func TestScrapeBatchBoundsConcurrency(t *testing.T) {
+	fixture := readFixture(t, "feed.xml")
+	inFlight, maxInFlight := &atomic.Int32{}, &atomic.Int32{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			seen := maxInFlight.Load()
+			if n <= seen || maxInFlight.CompareAndSwap(seen, n) {
+				break
+			}
+		}
+		// long enough for the other fetches to pile up
+		time.Sleep(50 * time.Millisecond)
+		w.Write(fixture)
+	}))
+	defer srv.Close()
+
+	cfg := testConfig()
+	cfg.ScrapeConcurrency = 2
+	store := database.NewMemoryStore()
+	user := createTestUser(t, store)
+	for i := 0; i < 6; i++ {
+		createTestFeed(t, store, user, fmt.Sprintf("%s/feed/%d", srv.URL, i))
+	}
+
+	newTestScraper(t, store, cfg).scrapeBatch(context.Background())
+
+	if got := maxInFlight.Load(); got != int32(cfg.ScrapeConcurrency) {
+		t.Errorf("max fetches in flight = %d, want %d", got, cfg.ScrapeConcurrency)
+	}
+}
+
+func TestScrapeBatchOnlyFetchesBatchSize(t *testing.T) {
+	fixture := readFixture(t, "feed.xml")
+	requests := &atomic.Int32{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Write(fixture)
+	}))
+	defer srv.Close()
+
+	cfg := testConfig()
+	cfg.ScrapeBatchSize = 3
+	store := database.NewMemoryStore()
+	user := createTestUser(t, store)
+	for i := 0; i < 5; i++ {
+		createTestFeed(t, store, user, fmt.Sprintf("%s/feed/%d", srv.URL, i))
+	}
+
+	newTestScraper(t, store, cfg).scrapeBatch(context.Background())
+
+	if got := requests.Load(); got != 3 {
+		t.Errorf("fetched %d feeds, want 3", got)
+	}
+}
+
+func TestScrapeFeedStoresPostsAndMarksFetched(t *testing.T) {
+	fixture := readFixture(t, "feed.xml")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Write(fixture)
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	store := database.NewMemoryStore()
+	user := createTestUser(t, store)
+	feed := createTestFeed(t, store, user, srv.URL+"/feed.xml")
+	if feed.LastFetchedAt.Valid {
+		t.Fatal("new feed already has last_fetched_at")
+	}
+	s := newTestScraper(t, store, testConfig())
+
+	before := time.Now().UTC()
+	inserted, err := s.scrapeFeed(ctx, feed)
+	if err != nil {
+		t.Fatalf("scrapeFeed: %v", err)
+	}
+	if inserted != 2 {
+		t.Errorf("inserted %d posts, want 2", inserted)
+	}
+
+	fetched, err := store.GetFeedByID(ctx, feed.ID)
+	if err != nil {
+		t.Fatalf("GetFeedByID: %v", err)
+	}
+	if !fetched.LastFetchedAt.Valid || fetched.LastFetchedAt.Time.Before(before) {
+		t.Errorf("last_fetched_at = %v, want a time after %v", fetched.LastFetchedAt, before)
+	}
+
+	posts, err := store.GetPosts(ctx, database.GetPostsParams{Limit: 10})
+	if err != nil {
+		t.Fatalf("GetPosts: %v", err)
+	}
+	if len(posts) != 2 {
+		t.Fatalf("stored %d posts, want 2", len(posts))
+	}
+	byGuid := map[string]database.Post{}
+	for _, post := range posts {
+		byGuid[post.Guid] = post
+	}
+	first, ok := byGuid["https://example.com/first"]
+	if !ok {
+		t.Fatalf("first post wasn't stored, got %+v", posts)
+	}
+	if first.Title != "First post" || first.Url != "https://example.com/first" || first.FeedID != feed.ID {
+		t.Errorf("first post = %+v", first)
+	}
+	wantPublished := time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)
+	if !first.PublishedAt.Equal(wantPublished) {
+		t.Errorf("published_at = %v, want %v", first.PublishedAt, wantPublished)
+	}
+
+	// the same items again are duplicates
+	inserted, err = s.scrapeFeed(ctx, fetched)
+	if err != nil {
+		t.Fatalf("second scrapeFeed: %v", err)
+	}
+	if inserted != 0 {
+		t.Errorf("second scrape inserted %d posts, want 0", inserted)
+	}
+}
+
+func TestScrapeFeedRecordsFailure(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "gone", http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	store := database.NewMemoryStore()
+	user := createTestUser(t, store)
+	feed := createTestFeed(t, store, user, srv.URL+"/feed.xml")
+
+	if _, err := newTestScraper(t, store, testConfig()).scrapeFeed(ctx, feed); err == nil {
+		t.Fatal("scrapeFeed succeeded against a failing server")
+	}
+
+	failed, err := store.GetFeedByID(ctx, feed.ID)
+	if err != nil {
+		t.Fatalf("GetFeedByID: %v", err)
+	}
+	if failed.FailureCount != 1 || failed.LastError == "" || !failed.NextFetchAt.Valid {
+		t.Errorf("failure wasn't recorded: %+v", failed)
+	}
+	if !failed.LastFetchedAt.Valid {
+		t.Error("a failed fetch should still set last_fetched_at")
+	}
+}
+
+func TestScrapeFeedIgnoresShutdown(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// shut down while the feed is being fetched
+		cancel()
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	store := database.NewMemoryStore()
+	user := createTestUser(t, store)
+	feed := createTestFeed(t, store, user, srv.URL+"/feed.xml")
+
+	s := newTestScraper(t, store, testConfig())
+	if _, err := s.scrapeFeed(ctx, feed); err == nil {
+		t.Fatal("scrapeFeed succeeded although it was cancelled")
+	}
+
+	got, err := store.GetFeedByID(context.Background(), feed.ID)
+	if err != nil {
+		t.Fatalf("GetFeedByID: %v", err)
+	}
+	if got.FailureCount != 0 || got.LastError != "" || got.NextFetchAt.Valid || got.DeadAt.Valid {
+		t.Errorf("a cancelled fetch was recorded as a failure: %+v", got)
+	}
+	if n := s.metrics.scraperFetchFailures; n != 0 {
+		t.Errorf("fetch failures = %d, want 0", n)
+	}
+}
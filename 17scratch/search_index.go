@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/anishakd4/rssagg/internal/database"
+	"github.com/anishakd4/rssagg/internal/search"
+)
+
+const (
+	// indexBatchSize is how many posts are read from storage at a time while
+	// building the index
+	indexBatchSize = 500
+	// indexRetryBase is the wait after storage first fails during a build, it
+	// doubles with every failure after that up to indexRetryMax
+	indexRetryBase = time.Second
+	indexRetryMax  = time.Minute
+)
+
+func postDocument(post database.Post) search.Document {
+	return search.Document{
+		ID:          post.ID,
+		FeedID:      post.FeedID,
+		Title:       post.Title,
+		Body:        post.Description,
+		PublishedAt: post.PublishedAt,
+	}
+}
+
+// searchIndexer fills the search index with the posts already in storage
+// when the server starts. The scraper adds new ones as it stores them, so
+// the index only lives in memory whichever storage backend is used.
+type searchIndexer struct {
+	db    database.Store
+	index *search.Index
+	built *atomic.Bool
+}
+
+func newSearchIndexer(db database.Store, index *search.Index) *searchIndexer {
+	return &searchIndexer{
+		db:    db,
+		index: index,
+		built: &atomic.Bool{},
+	}
+}
+
+// build indexes every stored post. A post the scraper adds in the meantime
+// may be added twice, which just indexes it again. When storage fails it
+// carries on where it left off after a backoff, until ctx is cancelled, so
+// the readiness check doesn't stay failed for good.
+func (s *searchIndexer) build(ctx context.Context) {
+	start := time.Now()
+	after := database.Cursor{}
+	retry := indexRetryBase
+	for {
+		posts, err := s.db.GetPosts(ctx, database.GetPostsParams{
+			After: after,
+			Limit: indexBatchSize,
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("Couldn't build search index, retrying in %s: %v", retry, err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(retry):
+			}
+			retry = min(retry*2, indexRetryMax)
+			continue
+		}
+		retry = indexRetryBase
+		for _, post := range posts {
+			s.index.Add(postDocument(post))
+		}
+		if len(posts) < indexBatchSize {
+			break
+		}
+		last := posts[len(posts)-1]
+		after = database.Cursor{Time: last.CreatedAt, ID: last.ID, Valid: true}
+	}
+	s.built.Store(true)
+	log.Printf("Search index built, %v posts in %s", s.index.Len(), time.Since(start).Round(time.Millisecond))
+}
+
+// checkBuilt fails until build has finished, search results would be
+// missing older posts before then
+func (s *searchIndexer) checkBuilt(ctx context.Context) error {
+	if !s.built.Load() {
+		return errors.New("search index is still being built")
+	}
+	return nil
+}
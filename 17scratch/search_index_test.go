@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/anishakd4/rssagg/internal/database"
+	"github.com/anishakd4/rssagg/internal/search"
+	"github.com/google/uuid"
+)
+
+// flakyPostsStore fails GetPosts the first failures times it's called
+type flakyPostsStore struct {
+	database.Store
+	failures *atomic.Int32
+}
+
+func (s flakyPostsStore) GetPosts(ctx context.Context, arg database.GetPostsParams) ([]database.Post, error) {
+	if s.failures.Add(-1) >= 0 {
+		return nil, errors.New("storage is down")
+	}
+	return s.Store.GetPosts(ctx, arg)
+}
+
+func TestSearchIndexerRetriesBuild(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	store := database.NewMemoryStore()
+	user := createTestUser(t, store)
+	feed := createTestFeed(t, store, user, "https://example.com/feed.xml")
+	now := time.Now().UTC()
+	if _, err := store.CreatePost(ctx, database.CreatePostParams{
+		ID:          uuid.New(),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		Title:       "Indexed after a retry",
+		Url:         "https://example.com/post",
+		PublishedAt: now,
+		Guid:        "post",
+		FeedID:      feed.ID,
+	}); err != nil {
+		t.Fatalf("CreatePost: %v", err)
+	}
+
+	failures := &atomic.Int32{}
+	failures.Store(1)
+	indexer := newSearchIndexer(flakyPostsStore{Store: store, failures: failures}, search.NewIndex())
+	if err := indexer.checkBuilt(ctx); err == nil {
+		t.Fatal("checkBuilt passed before build ran")
+	}
+
+	indexer.build(ctx)
+
+	if err := indexer.checkBuilt(ctx); err != nil {
+		t.Errorf("checkBuilt after a failed first attempt: %v", err)
+	}
+	if got := indexer.index.Len(); got != 1 {
+		t.Errorf("indexed %d posts, want 1", got)
+	}
+}
+
+func TestSearchIndexerBuildStopsWithContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	failures := &atomic.Int32{}
+	failures.Store(1 << 30)
+	indexer := newSearchIndexer(flakyPostsStore{Store: database.NewMemoryStore(), failures: failures}, search.NewIndex())
+
+	done := make(chan struct{})
+	go func() {
+		indexer.build(ctx)
+		close(done)
+	}()
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("build didn't return after ctx was cancelled")
+	}
+	if err := indexer.checkBuilt(ctx); err == nil {
+		t.Error("checkBuilt passed although the build never finished")
+	}
+}
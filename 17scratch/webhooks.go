@@ -0,0 +1,274 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/anishakd4/rssagg/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	webhookBatchSize   = 50
+	webhookConcurrency = 4
+	// webhookRetryBase is the wait after the first failed attempt, it doubles
+	// with every failure after that
+	webhookRetryBase = 30 * time.Second
+
+	webhookEventPostCreated = "post.created"
+
+	webhookSignatureHeader = "X-Rssagg-Signature"
+	webhookTimestampHeader = "X-Rssagg-Timestamp"
+)
+
+// webhookDispatcher sends new posts to the webhooks of users who follow the
+// feed. Deliveries are queued in storage first so a restart or a failing
+// endpoint doesn't lose them, and retried with exponential backoff until
+// maxAttempts, when they're dead lettered.
+type webhookDispatcher struct {
+	db          database.Store
+	metrics     *metrics
+	client      *http.Client
+	interval    time.Duration
+	maxAttempts int
+	maxBackoff  time.Duration
+	// wake starts a run early when new deliveries are queued
+	wake chan struct{}
+}
+
+func newWebhookDispatcher(db database.Store, m *metrics, cfg Config) *webhookDispatcher {
+	return &webhookDispatcher{
+		db:          db,
+		metrics:     m,
+		client:      newOutboundClient(cfg.WebhookTimeout, cfg.AllowPrivateNetworks),
+		interval:    cfg.WebhookInterval,
+		maxAttempts: cfg.WebhookMaxAttempts,
+		maxBackoff:  cfg.WebhookMaxBackoff,
+		wake:        make(chan struct{}, 1),
+	}
+}
+
+// enqueue queues a delivery of post for every webhook interested in it
+func (d *webhookDispatcher) enqueue(ctx context.Context, post database.Post) {
+	webhooks, err := d.db.GetWebhooksForFeed(ctx, post.FeedID)
+	if err != nil {
+		log.Printf("Couldn't get webhooks for post %s: %v", post.ID, err)
+		return
+	}
+	if len(webhooks) == 0 {
+		return
+	}
+
+	now := time.Now().UTC()
+	for _, webhook := range webhooks {
+		_, err := d.db.CreateWebhookDelivery(ctx, database.CreateWebhookDeliveryParams{
+			ID:            uuid.New(),
+			CreatedAt:     now,
+			UpdatedAt:     now,
+			WebhookID:     webhook.ID,
+			PostID:        post.ID,
+			NextAttemptAt: now,
+		})
+		// the webhook was deleted in the meantime
+		if database.IsForeignKeyViolation(err) {
+			continue
+		}
+		if err != nil {
+			log.Printf("Couldn't queue delivery of post %s to webhook %s: %v", post.ID, webhook.ID, err)
+		}
+	}
+
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// start sends due deliveries every interval, or sooner when woken, until ctx
+// is cancelled
+func (d *webhookDispatcher) start(ctx context.Context) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+	for {
+		d.deliverDue(ctx)
+
+		select {
+		case <-ctx.Done():
+			log.Println("Webhook dispatcher stopped")
+			return
+		case <-ticker.C:
+		case <-d.wake:
+		}
+	}
+}
+
+// deliverDue works through due deliveries a batch at a time, keeping at most
+// webhookConcurrency requests in flight
+func (d *webhookDispatcher) deliverDue(ctx context.Context) {
+	for ctx.Err() == nil {
+		deliveries, err := d.db.GetDueWebhookDeliveries(ctx, database.GetDueWebhookDeliveriesParams{
+			Now:   time.Now().UTC(),
+			Limit: webhookBatchSize,
+		})
+		if err != nil {
+			log.Println("Couldn't get due webhook deliveries:", err)
+			return
+		}
+
+		sem := make(chan struct{}, webhookConcurrency)
+		wg := &sync.WaitGroup{}
+		handled := &atomic.Int64{}
+		for _, delivery := range deliveries {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(delivery database.WebhookDelivery) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				if d.deliver(ctx, delivery) {
+					handled.Add(1)
+				}
+			}(delivery)
+		}
+		wg.Wait()
+
+		// a full batch means there may be more due, unless none of it could
+		// be recorded, then the next query would return the same deliveries
+		if len(deliveries) < webhookBatchSize || handled.Load() == 0 {
+			return
+		}
+	}
+}
+
+// deliver sends delivery and records how it went. It reports whether the
+// delivery is no longer due, i.e. whether the outcome was recorded.
+func (d *webhookDispatcher) deliver(ctx context.Context, delivery database.WebhookDelivery) bool {
+	webhook, err := d.db.GetWebhookByID(ctx, delivery.WebhookID)
+	if err != nil {
+		log.Printf("Couldn't get webhook %s: %v", delivery.WebhookID, err)
+		return d.recordFailure(ctx, delivery, errors.New("couldn't load the webhook"))
+	}
+	post, err := d.db.GetPostByID(ctx, delivery.PostID)
+	if err != nil {
+		log.Printf("Couldn't get post %s: %v", delivery.PostID, err)
+		return d.recordFailure(ctx, delivery, errors.New("couldn't load the post"))
+	}
+
+	if err := d.send(ctx, webhook, delivery, post); err != nil {
+		// shutting down, leave the delivery due for the next start
+		if ctx.Err() != nil {
+			return false
+		}
+		return d.recordFailure(ctx, delivery, err)
+	}
+	d.metrics.webhookDelivered()
+	if _, err := d.db.MarkWebhookDeliverySucceeded(ctx, delivery.ID); err != nil {
+		log.Printf("Couldn't record webhook delivery %s: %v", delivery.ID, err)
+		return false
+	}
+	return true
+}
+
+// send POSTs the payload signed with the webhook's secret. The signature is
+// an HMAC-SHA256 of "<timestamp>.<body>", hex encoded, so receivers can
+// reject replays of old payloads.
+func (d *webhookDispatcher) send(ctx context.Context, webhook database.Webhook, delivery database.WebhookDelivery, post database.Post) error {
+	type payload struct {
+		ID        uuid.UUID `json:"id"`
+		Event     string    `json:"event"`
+		CreatedAt time.Time `json:"created_at"`
+		Post      Post      `json:"post"`
+	}
+	body, err := json.Marshal(payload{
+		ID:        delivery.ID,
+		Event:     webhookEventPostCreated,
+		CreatedAt: delivery.CreatedAt,
+		Post:      databasePostToPost(post),
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.Url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "rssagg")
+	req.Header.Set("X-Rssagg-Event", webhookEventPostCreated)
+	req.Header.Set("X-Rssagg-Delivery", delivery.ID.String())
+	req.Header.Set(webhookTimestampHeader, timestamp)
+	req.Header.Set(webhookSignatureHeader, "sha256="+signWebhookPayload(webhook.Secret, timestamp, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// drain so the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	return nil
+}
+
+func signWebhookPayload(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// recordFailure schedules the next attempt, or dead letters the delivery
+// after maxAttempts attempts. It reports whether that was recorded.
+func (d *webhookDispatcher) recordFailure(ctx context.Context, delivery database.WebhookDelivery, sendErr error) bool {
+	attempts := int(delivery.Attempts) + 1
+	now := time.Now().UTC()
+	arg := database.MarkWebhookDeliveryFailedParams{
+		ID:            delivery.ID,
+		LastError:     sendErr.Error(),
+		NextAttemptAt: now.Add(d.backoff(attempts)),
+	}
+	dead := attempts >= d.maxAttempts
+	if dead {
+		arg.DeadAt = sql.NullTime{Time: now, Valid: true}
+		log.Printf("Webhook delivery %s failed %v times, dead lettering it: %v", delivery.ID, attempts, sendErr)
+	} else {
+		log.Printf("Webhook delivery %s failed, retrying in %s: %v", delivery.ID, d.backoff(attempts), sendErr)
+	}
+	d.metrics.webhookFailed(dead)
+
+	if _, err := d.db.MarkWebhookDeliveryFailed(ctx, arg); err != nil {
+		log.Printf("Couldn't record failed webhook delivery %s: %v", delivery.ID, err)
+		return false
+	}
+	return true
+}
+
+func (d *webhookDispatcher) backoff(attempts int) time.Duration {
+	wait := webhookRetryBase
+	for i := 1; i < attempts; i++ {
+		wait *= 2
+		if wait >= d.maxBackoff {
+			return d.maxBackoff
+		}
+	}
+	return min(wait, d.maxBackoff)
+}